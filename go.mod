@@ -12,6 +12,7 @@ require (
 	github.com/yuin/goldmark v1.7.8
 	go.mau.fi/util v0.8.3
 	golang.org/x/net v0.32.0
+	golang.org/x/sync v0.10.0
 	gopkg.in/yaml.v3 v3.0.1
 	maunium.net/go/mautrix v0.22.2-0.20241223114659-ba210a16b992
 )
@@ -36,7 +37,6 @@ require (
 	go.mau.fi/zeroconfig v0.1.3 // indirect
 	golang.org/x/crypto v0.31.0 // indirect
 	golang.org/x/exp v0.0.0-20241215155358-4a5509556b9e // indirect
-	golang.org/x/sync v0.10.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
@@ -27,6 +27,7 @@ import (
 	"maunium.net/go/mautrix/bridgev2/database"
 	"maunium.net/go/mautrix/bridgev2/networkid"
 
+	"go.mau.fi/mautrix-slack/pkg/msgconv"
 	"go.mau.fi/mautrix-slack/pkg/slackid"
 )
 
@@ -95,10 +96,17 @@ func (s *SlackClient) FetchMessages(ctx context.Context, params bridgev2.FetchMe
 	}
 	convertedMessages := make([]*bridgev2.BackfillMessage, 0, len(chunk.Messages))
 	var maxMsgID string
+	bridgedCache := make(map[string]bool)
+	isBridged := func(ts string) bool {
+		return s.isMessageBridged(ctx, params.Portal, channelID, ts, bridgedCache)
+	}
 	for _, msg := range chunk.Messages {
 		if threadTS != "" && msg.Timestamp == threadTS {
 			continue
-		} else if threadTS == "" && msg.ThreadTimestamp != "" && msg.ThreadTimestamp != msg.Timestamp {
+		} else if threadTS == "" && msg.ThreadTimestamp != "" && msg.ThreadTimestamp != msg.Timestamp &&
+			!shouldBackfillHistoryReply(&msg.Msg, isBridged) {
+			continue
+		} else if s.Main.shouldSuppressSystemMessage(params.Portal, msg.SubType) || s.Main.shouldSuppressPrompt(&msg.Msg) {
 			continue
 		}
 		convertedMessages = append(convertedMessages, s.wrapBackfillMessage(ctx, params.Portal, &msg.Msg, threadTS != ""))
@@ -117,6 +125,29 @@ func (s *SlackClient) FetchMessages(ctx context.Context, params bridgev2.FetchMe
 	}, nil
 }
 
+// shouldBackfillHistoryReply checks whether a thread reply found in the channel history should be backfilled.
+// Replies are normally bridged when backfilling the thread itself, but if the root was bridged earlier,
+// the thread won't be backfilled again, so replies found in the history are attached to the existing root.
+// The history only contains replies that were also sent to the channel, which the thread backfill
+// includes too, so replies that are already bridged are skipped.
+func shouldBackfillHistoryReply(msg *slack.Msg, isBridged func(ts string) bool) bool {
+	return isBridged(msg.ThreadTimestamp) && !isBridged(msg.Timestamp)
+}
+
+// isMessageBridged checks whether the given message already exists in the database.
+// Results are stored in the cache map, as many replies in one batch tend to share a root.
+func (s *SlackClient) isMessageBridged(ctx context.Context, portal *bridgev2.Portal, channelID, ts string, cache map[string]bool) bool {
+	if bridged, ok := cache[ts]; ok {
+		return bridged
+	}
+	msg, err := s.Main.br.DB.Message.GetFirstPartByID(ctx, portal.Receiver, slackid.MakeMessageID(s.TeamID, channelID, ts))
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Str("message_ts", ts).Msg("Failed to check if message is bridged")
+	}
+	cache[ts] = msg != nil
+	return msg != nil
+}
+
 func (s *SlackClient) wrapBackfillMessage(ctx context.Context, portal *bridgev2.Portal, msg *slack.Msg, inThread bool) *bridgev2.BackfillMessage {
 	senderID := msg.User
 	if senderID == "" {
@@ -135,7 +166,7 @@ func (s *SlackClient) wrapBackfillMessage(ctx context.Context, portal *bridgev2.
 	}
 	_, channelID := slackid.ParsePortalID(portal.ID)
 	out := &bridgev2.BackfillMessage{
-		ConvertedMessage: s.Main.MsgConv.ToMatrix(ctx, portal, intent, s.UserLogin, msg),
+		ConvertedMessage: s.Main.MsgConv.ToMatrix(msgconv.WithBackfill(ctx), portal, intent, s.UserLogin, msg),
 		Sender:           sender,
 		ID:               slackid.MakeMessageID(s.TeamID, channelID, msg.Timestamp),
 		Timestamp:        slackid.ParseSlackTimestamp(msg.Timestamp),
@@ -0,0 +1,43 @@
+// mautrix-slack - A Matrix-Slack puppeting bridge.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package connector
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldBackfillHistoryReply(t *testing.T) {
+	reply := &slack.Msg{Timestamp: "1700000002.000000", ThreadTimestamp: "1700000001.000000", SubType: "thread_broadcast"}
+	bridged := map[string]bool{}
+	isBridged := func(ts string) bool {
+		return bridged[ts]
+	}
+
+	// The root isn't bridged yet, so the reply will be bridged when backfilling the thread
+	assert.False(t, shouldBackfillHistoryReply(reply, isBridged))
+
+	// The root was bridged earlier, so the reply is attached to it
+	bridged[reply.ThreadTimestamp] = true
+	assert.True(t, shouldBackfillHistoryReply(reply, isBridged))
+
+	// Re-backfill after the thread backfill already bridged the reply
+	bridged[reply.Timestamp] = true
+	assert.False(t, shouldBackfillHistoryReply(reply, isBridged))
+}
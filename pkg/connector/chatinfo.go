@@ -18,6 +18,7 @@ package connector
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"slices"
@@ -31,8 +32,10 @@ import (
 	"github.com/slack-go/slack"
 	"go.mau.fi/util/jsontime"
 	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix"
 	"maunium.net/go/mautrix/bridgev2"
 	"maunium.net/go/mautrix/bridgev2/database"
+	"maunium.net/go/mautrix/bridgev2/matrix"
 	"maunium.net/go/mautrix/bridgev2/networkid"
 	"maunium.net/go/mautrix/event"
 
@@ -41,7 +44,52 @@ import (
 
 const ChatInfoCacheExpiry = 1 * time.Hour
 
+var ErrInformationBarrier = errors.New("conversation is blocked by an information barrier")
+
+func isInformationBarrierError(err error) bool {
+	return err != nil && err.Error() == "information_barrier_restricted"
+}
+
+// BarrierBlockExpiry is how long a conversation is skipped after hitting an information barrier
+// before trying to access it again, as barriers can be lifted by workspace admins.
+const BarrierBlockExpiry = 1 * time.Hour
+
+// markBarrierBlocked remembers that a conversation can't be accessed due to an information barrier,
+// so that it's skipped in future syncs instead of failing every time. It only logs the first time.
+func (s *SlackClient) markBarrierBlocked(ctx context.Context, channelID string, err error) {
+	s.barrierBlockedLock.Lock()
+	_, alreadyBlocked := s.barrierBlocked[channelID]
+	s.barrierBlocked[channelID] = time.Now()
+	s.barrierBlockedLock.Unlock()
+	if !alreadyBlocked {
+		zerolog.Ctx(ctx).Warn().Err(err).
+			Str("channel_id", channelID).
+			Msg("Conversation is blocked by an information barrier, skipping it")
+	}
+}
+
+func (s *SlackClient) isBarrierBlocked(channelID string) bool {
+	s.barrierBlockedLock.Lock()
+	defer s.barrierBlockedLock.Unlock()
+	blockedAt, blocked := s.barrierBlocked[channelID]
+	if blocked && time.Since(blockedAt) > BarrierBlockExpiry {
+		delete(s.barrierBlocked, channelID)
+		return false
+	}
+	return blocked
+}
+
+// clearBarrierBlocked forgets the information barrier of a conversation after it has been successfully accessed.
+func (s *SlackClient) clearBarrierBlocked(channelID string) {
+	s.barrierBlockedLock.Lock()
+	delete(s.barrierBlocked, channelID)
+	s.barrierBlockedLock.Unlock()
+}
+
 func (s *SlackClient) fetchChatInfoWithCache(ctx context.Context, channelID string) (*slack.Channel, error) {
+	if s.isBarrierBlocked(channelID) {
+		return nil, ErrInformationBarrier
+	}
 	s.chatInfoCacheLock.Lock()
 	defer s.chatInfoCacheLock.Unlock()
 	if cached, ok := s.chatInfoCache[channelID]; ok && time.Since(cached.ts) < ChatInfoCacheExpiry {
@@ -52,7 +100,10 @@ func (s *SlackClient) fetchChatInfoWithCache(ctx context.Context, channelID stri
 		IncludeLocale:     true,
 		IncludeNumMembers: true,
 	})
-	if err != nil {
+	if isInformationBarrierError(err) {
+		s.markBarrierBlocked(ctx, channelID, err)
+		return nil, fmt.Errorf("%w: %w", ErrInformationBarrier, err)
+	} else if err != nil {
 		return nil, err
 	}
 	s.chatInfoCache[channelID] = chatInfoCacheEntry{
@@ -218,6 +269,22 @@ func (s *SlackClient) wrapChatInfo(ctx context.Context, info *slack.Channel, isN
 			IsNoteToSelf: info.IsIM && info.User == s.UserID,
 		}))
 	}
+	if roomType != database.RoomTypeDM {
+		updatePurpose := s.makePurposeUpdater(info.Purpose.Value)
+		extraUpdates = func(ctx context.Context, portal *bridgev2.Portal) bool {
+			changed := updatePurpose(ctx, portal)
+			meta := portal.Metadata.(*slackid.PortalMetadata)
+			if info.NumMembers > 0 && meta.MemberCount != info.NumMembers {
+				meta.MemberCount = info.NumMembers
+				changed = true
+			}
+			if meta.IsGeneral != info.IsGeneral {
+				meta.IsGeneral = info.IsGeneral
+				changed = true
+			}
+			return changed
+		}
+	}
 	return &bridgev2.ChatInfo{
 		Name:         name,
 		Topic:        ptr.Ptr(info.Topic.Value),
@@ -231,6 +298,34 @@ func (s *SlackClient) wrapChatInfo(ctx context.Context, info *slack.Channel, isN
 	}, nil
 }
 
+var StateSlackPurpose = event.Type{Type: "fi.mau.slack.purpose", Class: event.StateEventType}
+
+// makePurposeUpdater returns an ExtraUpdates function that bridges the channel purpose
+// as a separate state event, so that it doesn't get mixed into the room topic.
+func (s *SlackClient) makePurposeUpdater(purpose string) func(ctx context.Context, portal *bridgev2.Portal) bool {
+	return func(ctx context.Context, portal *bridgev2.Portal) bool {
+		meta := portal.Metadata.(*slackid.PortalMetadata)
+		if portal.MXID == "" || meta.Purpose == purpose {
+			return false
+		}
+		_, err := s.Main.br.Bot.SendState(ctx, portal.MXID, StateSlackPurpose, "", &event.Content{
+			Raw: map[string]any{"purpose": purpose},
+		}, time.Time{})
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Msg("Failed to send channel purpose state event")
+			return false
+		}
+		meta.Purpose = purpose
+		return true
+	}
+}
+
+func (s *SlackClient) invalidateChatInfoCache(channelID string) {
+	s.chatInfoCacheLock.Lock()
+	delete(s.chatInfoCache, channelID)
+	s.chatInfoCacheLock.Unlock()
+}
+
 func (s *SlackClient) fetchChatInfo(ctx context.Context, channelID string, isNew bool) (*bridgev2.ChatInfo, error) {
 	info, err := s.fetchChatInfoWithCache(ctx, channelID)
 	if err != nil {
@@ -329,8 +424,13 @@ func (s *SlackClient) wrapUserInfo(userID string, info *slack.User, botInfo *sla
 		avatar = makeAvatar(botInfo.Icons.Image72, botInfo.Icons.Image72)
 		isBot = true
 	}
+	oldName, oldAvatarMXC := ghost.Name, ghost.AvatarMXC
+	identifiers := []string{fmt.Sprintf("slack-internal:%s", userID)}
+	if info != nil {
+		identifiers = append(identifiers, s.Main.Config.getProfileFieldIdentifiers(&info.Profile)...)
+	}
 	return &bridgev2.UserInfo{
-		Identifiers: []string{fmt.Sprintf("slack-internal:%s", userID)},
+		Identifiers: identifiers,
 		Name:        name,
 		Avatar:      avatar,
 		IsBot:       &isBot,
@@ -345,12 +445,190 @@ func (s *SlackClient) wrapUserInfo(userID string, info *slack.User, botInfo *sla
 			if extraUpdateAvatarID != "" {
 				ghost.AvatarID = extraUpdateAvatarID
 			}
+			if (ghost.Name != oldName || ghost.AvatarMXC != oldAvatarMXC) && s.hasNicknameOverride(userID) {
+				// Profile changes rewrite the member events in every room, so the override has to be reapplied
+				go s.applyNicknameOverride(context.WithoutCancel(ctx), ghost)
+			}
+			if info != nil && s.Main.Config.StatusPresence {
+				statusMessage, expiry := s.Main.Config.formatStatusMessage(&info.Profile)
+				s.Main.updateGhostStatus(ctx, ghost, info.Presence, statusMessage, expiry)
+			}
 			return true
 		},
 	}
 }
 
-func (s *SlackClient) syncManyUsers(ctx context.Context, ghosts map[string]*bridgev2.Ghost) {
+// setGhostPresence sets the Matrix presence status message of a ghost based on its Slack status.
+// Slack usually doesn't include the presence itself in user info, so the ghost's current Matrix presence
+// is kept when it's unknown. Presence isn't part of the bridgev2 Matrix API, so this only works with
+// the standard Matrix connector.
+func setGhostPresence(ctx context.Context, ghost *bridgev2.Ghost, slackPresence, statusMessage string) bool {
+	asIntent, ok := ghost.Intent.(*matrix.ASIntent)
+	if !ok {
+		return false
+	}
+	var presence event.Presence
+	switch slackPresence {
+	case "active":
+		presence = event.PresenceOnline
+	case "away":
+		presence = event.PresenceUnavailable
+	default:
+		presence = event.PresenceOffline
+		current, err := asIntent.Matrix.GetOwnPresence(ctx)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Str("ghost_id", string(ghost.ID)).Msg("Failed to get current ghost presence")
+		} else if current.Presence != "" {
+			presence = current.Presence
+		}
+	}
+	err := asIntent.Matrix.SetPresence(ctx, mautrix.ReqPresence{
+		Presence:  presence,
+		StatusMsg: statusMessage,
+	})
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Str("ghost_id", string(ghost.ID)).Msg("Failed to set ghost presence")
+		return false
+	}
+	return true
+}
+
+// updateGhostStatus sets the presence status message of a ghost if it has changed and schedules clearing it when
+// it expires. Status fields in the ghost metadata are only accessed with statusLock held, as expiry timers run in
+// their own goroutines.
+func (s *SlackConnector) updateGhostStatus(ctx context.Context, ghost *bridgev2.Ghost, slackPresence, statusMessage string, expiry time.Time) {
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	meta := ghost.Metadata.(*slackid.GhostMetadata)
+	if statusMessage == meta.StatusMessage && expiry.Equal(meta.StatusExpiry.Time) {
+		return
+	}
+	if setGhostPresence(ctx, ghost, slackPresence, statusMessage) {
+		meta.StatusMessage = statusMessage
+		meta.StatusExpiry = jsontime.U(expiry)
+		s.scheduleStatusExpiry(ctx, ghost.ID, expiry)
+	}
+}
+
+// scheduleStatusExpiry replaces the expiry timer of a ghost. The caller must hold statusLock.
+func (s *SlackConnector) scheduleStatusExpiry(ctx context.Context, ghostID networkid.UserID, expiry time.Time) {
+	if timer, ok := s.statusTimers[ghostID]; ok {
+		timer.Stop()
+		delete(s.statusTimers, ghostID)
+	}
+	if expiry.IsZero() {
+		return
+	}
+	// Clear the status when it expires without relying on Slack sending a profile update for it
+	log := zerolog.Ctx(ctx).With().Str("ghost_id", string(ghostID)).Logger()
+	s.statusTimers[ghostID] = time.AfterFunc(time.Until(expiry), func() {
+		s.expireGhostStatus(log.WithContext(context.Background()), ghostID, expiry)
+	})
+}
+
+// expireGhostStatus clears the status message of a ghost, unless it has been changed since the expiry was scheduled.
+func (s *SlackConnector) expireGhostStatus(ctx context.Context, ghostID networkid.UserID, expiry time.Time) {
+	ghost, err := s.br.GetExistingGhostByID(ctx, ghostID)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to get ghost to clear expired status")
+		return
+	} else if ghost == nil {
+		return
+	}
+	s.statusLock.Lock()
+	defer s.statusLock.Unlock()
+	meta := ghost.Metadata.(*slackid.GhostMetadata)
+	if meta.StatusMessage == "" || !meta.StatusExpiry.Time.Equal(expiry) {
+		return
+	}
+	delete(s.statusTimers, ghostID)
+	if !setGhostPresence(ctx, ghost, "", "") {
+		return
+	}
+	meta.StatusMessage = ""
+	meta.StatusExpiry = jsontime.Unix{}
+	err = s.br.DB.Ghost.Update(ctx, ghost.Ghost)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to save ghost after clearing expired status")
+	}
+}
+
+// restoreStatusExpiries clears statuses that expired while the bridge was offline and reschedules the timers
+// of the rest, as timers don't survive restarts.
+func (s *SlackClient) restoreStatusExpiries(ctx context.Context) {
+	log := zerolog.Ctx(ctx)
+	ghostIDs, err := s.Main.DB.GetGhostIDsWithStatus(ctx, s.Main.br.ID, s.TeamID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get ghosts with status messages")
+		return
+	}
+	for _, ghostID := range ghostIDs {
+		ghost, err := s.Main.br.GetExistingGhostByID(ctx, ghostID)
+		if err != nil {
+			log.Err(err).Str("ghost_id", string(ghostID)).Msg("Failed to get ghost to restore status expiry")
+			continue
+		} else if ghost == nil {
+			continue
+		}
+		s.Main.statusLock.Lock()
+		expiry := ghost.Metadata.(*slackid.GhostMetadata).StatusExpiry.Time
+		_, scheduled := s.Main.statusTimers[ghostID]
+		if !expiry.IsZero() && !scheduled {
+			// Timers with a negative duration fire immediately, which clears already expired statuses
+			s.Main.scheduleStatusExpiry(ctx, ghostID, expiry)
+		}
+		s.Main.statusLock.Unlock()
+	}
+}
+
+func (s *SlackClient) hasNicknameOverride(userID string) bool {
+	_, ok := s.UserLogin.Metadata.(*slackid.UserLoginMetadata).NicknameOverrides[userID]
+	return ok
+}
+
+// applyNicknameOverride sets the ghost's member event in the user's rooms to the nickname override.
+// Member events are visible to everyone in the room, so this is only done in portals that belong
+// to this login alone (DMs, group DMs and channels when split portals are enabled).
+func (s *SlackClient) applyNicknameOverride(ctx context.Context, ghost *bridgev2.Ghost) {
+	log := zerolog.Ctx(ctx).With().Str("ghost_id", string(ghost.ID)).Logger()
+	_, userID := slackid.ParseUserID(ghost.ID)
+	name, ok := s.UserLogin.Metadata.(*slackid.UserLoginMetadata).NicknameOverrides[userID]
+	if !ok {
+		name = ghost.Name
+	}
+	userPortals, err := s.UserLogin.Bridge.DB.UserPortal.GetAllForLogin(ctx, s.UserLogin.UserLogin)
+	if err != nil {
+		log.Err(err).Msg("Failed to get user portals to apply nickname override")
+		return
+	}
+	ghostMXID := ghost.Intent.GetMXID()
+	for _, up := range userPortals {
+		if up.Portal.Receiver != s.UserLogin.ID {
+			continue
+		}
+		portal, err := s.Main.br.GetExistingPortalByKey(ctx, up.Portal)
+		if err != nil {
+			log.Err(err).Object("portal_key", up.Portal).Msg("Failed to get portal to apply nickname override")
+			continue
+		} else if portal == nil || portal.MXID == "" {
+			continue
+		}
+		member, err := s.Main.br.Matrix.GetMemberInfo(ctx, portal.MXID, ghostMXID)
+		if err != nil {
+			log.Err(err).Stringer("room_id", portal.MXID).Msg("Failed to get ghost member info")
+			continue
+		} else if member == nil || member.Membership != event.MembershipJoin || member.Displayname == name {
+			continue
+		}
+		member.Displayname = name
+		_, err = ghost.Intent.SendState(ctx, portal.MXID, event.StateMember, ghostMXID.String(), &event.Content{Parsed: member}, time.Time{})
+		if err != nil {
+			log.Err(err).Stringer("room_id", portal.MXID).Msg("Failed to apply nickname override")
+		}
+	}
+}
+
+func (s *SlackClient) syncManyUsers(ctx context.Context, ghosts map[string]*bridgev2.Ghost, force bool) {
 	params := slack.GetCachedUsersParameters{
 		CheckInteraction:        true,
 		IncludeProfileOnlyUsers: true,
@@ -359,7 +637,12 @@ func (s *SlackClient) syncManyUsers(ctx context.Context, ghosts map[string]*brid
 	for _, ghost := range ghosts {
 		meta := ghost.Metadata.(*slackid.GhostMetadata)
 		_, userID := slackid.ParseUserID(ghost.ID)
-		params.UpdatedIDs[userID] = meta.SlackUpdatedTS
+		if force {
+			// Slack only returns users that have changed since the given timestamp
+			params.UpdatedIDs[userID] = 0
+		} else {
+			params.UpdatedIDs[userID] = meta.SlackUpdatedTS
+		}
 	}
 	zerolog.Ctx(ctx).Debug().Any("request_map", params.UpdatedIDs).Msg("Requesting user info")
 	infos, err := s.Client.GetUsersCacheContext(ctx, s.TeamID, params)
@@ -44,6 +44,8 @@ func init() {
 		"slack-user-removed-from-team": "You were removed from the Slack workspace",
 		"slack-id-mismatch":            "Unexpected internal error: got different user ID",
 	})
+	// Parse RTM message events with the huddle room and call objects that slackgo doesn't know about
+	slack.EventMapping["message"] = rtmMessageEvent{}
 }
 
 func makeSlackClient(log *zerolog.Logger, token, cookieToken, appToken string) *slack.Client {
@@ -62,6 +64,11 @@ func makeSlackClient(log *zerolog.Logger, token, cookieToken, appToken string) *
 func (s *SlackConnector) LoadUserLogin(ctx context.Context, login *bridgev2.UserLogin) error {
 	teamID, userID := slackid.ParseUserLoginID(login.ID)
 	meta := login.Metadata.(*slackid.UserLoginMetadata)
+	if oldClient, ok := login.Client.(*SlackClient); ok {
+		// When re-logging in, the token type may have changed, so make sure the old transport is fully stopped
+		// before replacing the client to avoid both RTM and socket mode receiving events.
+		oldClient.Disconnect()
+	}
 	var sc *SlackClient
 	if meta.Token == "" {
 		sc = &SlackClient{Main: s, UserLogin: login, UserID: userID, TeamID: teamID}
@@ -77,18 +84,12 @@ func (s *SlackConnector) LoadUserLogin(ctx context.Context, login *bridgev2.User
 
 			chatInfoCache:   make(map[string]chatInfoCacheEntry),
 			lastReadCache:   make(map[string]string),
+			barrierBlocked:  make(map[string]time.Time),
+			reactionEchoes:  make(map[string]time.Time),
+			emojiPackHashes: make(map[string][32]byte),
 			userResyncQueue: make(chan *bridgev2.Ghost, 16),
 		}
-		if sc.IsRealUser {
-			sc.RTM = client.NewRTM()
-		} else {
-			log := login.Log.With().Str("component", "slackgo socketmode").Logger()
-			sc.SocketMode = socketmode.New(
-				sc.Client,
-				socketmode.OptionLog(slackgoZerolog{Logger: log}),
-				socketmode.OptionDebug(log.GetLevel() == zerolog.TraceLevel),
-			)
-		}
+		sc.resetTransport()
 	}
 	teamPortalKey := sc.makeTeamPortalKey(teamID)
 	var err error
@@ -122,11 +123,21 @@ type SlackClient struct {
 	stopResyncQueue atomic.Pointer[context.CancelFunc]
 	userResyncQueue chan *bridgev2.Ghost
 	initialConnect  time.Time
+	lastConnect     atomic.Int64
 
 	chatInfoCache     map[string]chatInfoCacheEntry
 	chatInfoCacheLock sync.Mutex
 	lastReadCache     map[string]string
 	lastReadCacheLock sync.Mutex
+
+	barrierBlocked     map[string]time.Time
+	barrierBlockedLock sync.Mutex
+
+	reactionEchoes     map[string]time.Time
+	reactionEchoesLock sync.Mutex
+
+	emojiPackHashes map[string][32]byte
+	emojiPackLock   sync.Mutex
 }
 
 var (
@@ -135,6 +146,23 @@ var (
 	_ status.StandaloneCustomBridgeStateFiller = (*SlackClient)(nil)
 )
 
+// resetTransport clears any existing event transport and creates the one matching the current token type:
+// RTM for real user tokens and socket mode for bot tokens.
+func (s *SlackClient) resetTransport() {
+	s.RTM = nil
+	s.SocketMode = nil
+	if s.IsRealUser {
+		s.RTM = s.Client.NewRTM()
+	} else {
+		log := s.UserLogin.Log.With().Str("component", "slackgo socketmode").Logger()
+		s.SocketMode = socketmode.New(
+			s.Client,
+			socketmode.OptionLog(slackgoZerolog{Logger: log}),
+			socketmode.OptionDebug(log.GetLevel() == zerolog.TraceLevel),
+		)
+	}
+}
+
 func (s *SlackClient) GetClient() *slack.Client {
 	return s.Client
 }
@@ -162,6 +190,10 @@ func (s *SlackClient) Connect(ctx context.Context) {
 		})
 		return
 	}
+	if (s.IsRealUser && (s.RTM == nil || s.SocketMode != nil)) || (!s.IsRealUser && (s.SocketMode == nil || s.RTM != nil)) {
+		s.disconnect()
+		s.resetTransport()
+	}
 	var bootResp *slack.ClientUserBootResponse
 	if s.IsRealUser {
 		err := s.Client.FetchVersionData(ctx)
@@ -230,6 +262,9 @@ func (s *SlackClient) connect(ctx context.Context, bootResp *slack.ClientUserBoo
 		go s.consumeSocketModeEvents()
 		go s.runSocketMode(ctx)
 	}
+	if s.Main.Config.StatusPresence {
+		go s.restoreStatusExpiries(ctx)
+	}
 	go s.SyncEmojis(ctx)
 	go s.SyncChannels(ctx)
 	return nil
@@ -283,7 +318,7 @@ func (s *SlackClient) resyncUsers() {
 				break CollectLoop
 			}
 		}
-		go s.syncManyUsers(ctx, entries)
+		go s.syncManyUsers(ctx, entries, false)
 		forceShortWait = false
 	}
 }
@@ -434,16 +469,23 @@ func (s *SlackClient) SyncChannels(ctx context.Context) {
 	for _, ch := range channels {
 		portalKey := s.makePortalKey(ch)
 		delete(existingPortals, portalKey)
+		if s.isBarrierBlocked(ch.ID) {
+			continue
+		}
 		var latestMessageID string
 		var hasCounts bool
 		if !s.IsRealUser {
+			channelID := ch.ID
 			ch, err = s.Client.GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{
-				ChannelID:         ch.ID,
+				ChannelID:         channelID,
 				IncludeLocale:     true,
 				IncludeNumMembers: true,
 			})
-			if err != nil {
-				log.Err(err).Str("channel_id", ch.ID).Msg("Failed to fetch channel info")
+			if isInformationBarrierError(err) {
+				s.markBarrierBlocked(ctx, channelID, err)
+				continue
+			} else if err != nil {
+				log.Err(err).Str("channel_id", channelID).Msg("Failed to fetch channel info")
 				continue
 			}
 			hasCounts = ch.Latest != nil
@@ -476,7 +518,7 @@ func (s *SlackClient) SyncChannels(ctx context.Context) {
 			continue
 		}
 		latestMessageID, ok := latestMessageIDs[channelID]
-		if !ok {
+		if !ok || s.isBarrierBlocked(channelID) {
 			// TODO delete portal if it's actually gone?
 			continue
 		}
@@ -0,0 +1,524 @@
+// mautrix-slack - A Matrix-Slack puppeting bridge.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/commands"
+	"maunium.net/go/mautrix/bridgev2/matrix"
+	"maunium.net/go/mautrix/bridgev2/networkid"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/mautrix-slack/pkg/slackid"
+)
+
+func (s *SlackConnector) registerCommands() {
+	s.br.Commands.(*commands.Processor).AddHandlers(
+		cmdSetNick,
+		cmdSharedInvites,
+		cmdAcceptSharedInvite,
+		cmdSystemMessages,
+		cmdResyncTeamUsers,
+		cmdBridgeBot,
+		cmdCleanupOrphans,
+	)
+}
+
+// getClientForCommand finds the login that should be used for a command. In portal rooms, the login in the
+// same team as the portal is preferred, otherwise the user's default login is used.
+func getClientForCommand(ce *commands.Event) *SlackClient {
+	login := ce.User.GetDefaultLogin()
+	if ce.Portal != nil {
+		portalTeamID, _ := slackid.ParsePortalID(ce.Portal.ID)
+		for _, userLogin := range ce.User.GetUserLogins() {
+			if teamID, _ := slackid.ParseUserLoginID(userLogin.ID); teamID == portalTeamID {
+				login = userLogin
+				break
+			}
+		}
+	}
+	if login == nil {
+		ce.Reply("You're not logged in")
+		return nil
+	}
+	client, ok := login.Client.(*SlackClient)
+	if !ok || !client.IsLoggedIn() {
+		ce.Reply("You're not logged into %s", login.RemoteName)
+		return nil
+	}
+	return client
+}
+
+// parseSlackUserIDArg accepts either a plain Slack user ID or a team-prefixed ghost ID
+// and returns the plain user ID if it belongs to the client's team.
+func (s *SlackClient) parseSlackUserIDArg(arg string) string {
+	if strings.ContainsRune(arg, '-') {
+		teamID, userID := slackid.ParseUserID(networkid.UserID(arg))
+		if teamID != s.TeamID {
+			return ""
+		}
+		return userID
+	}
+	return strings.ToUpper(arg)
+}
+
+var cmdSetNick = &commands.FullHandler{
+	Func: fnSetNick,
+	Name: "set-nick",
+	Help: commands.HelpMeta{
+		Section:     commands.HelpSectionChats,
+		Description: "Override the display name of a Slack user in your private rooms (DMs and split portals). Leave the name empty to remove the override.",
+		Args:        "<_Slack user ID_> [_name_]",
+	},
+	RequiresLogin: true,
+}
+
+func fnSetNick(ce *commands.Event) {
+	if len(ce.Args) == 0 {
+		ce.Reply("**Usage:** `$cmdprefix set-nick <Slack user ID> [name]`")
+		return
+	}
+	client := getClientForCommand(ce)
+	if client == nil {
+		return
+	}
+	userID := client.parseSlackUserIDArg(ce.Args[0])
+	if userID == "" {
+		ce.Reply("That user ID doesn't belong to the %s workspace", client.UserLogin.RemoteName)
+		return
+	}
+	nickname := strings.TrimSpace(strings.Join(ce.Args[1:], " "))
+	meta := client.UserLogin.Metadata.(*slackid.UserLoginMetadata)
+	if nickname == "" {
+		delete(meta.NicknameOverrides, userID)
+	} else {
+		if meta.NicknameOverrides == nil {
+			meta.NicknameOverrides = make(map[string]string)
+		}
+		meta.NicknameOverrides[userID] = nickname
+	}
+	err := client.UserLogin.Save(ce.Ctx)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to save user login after changing nickname override")
+		ce.Reply("Failed to save nickname override: %v", err)
+		return
+	}
+	ghost, err := ce.Bridge.GetGhostByID(ce.Ctx, slackid.MakeUserID(client.TeamID, userID))
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to get ghost to apply nickname override")
+		ce.Reply("Saved nickname override, but failed to apply it: %v", err)
+		return
+	}
+	client.applyNicknameOverride(ce.Ctx, ghost)
+	if nickname == "" {
+		ce.Reply("Removed nickname override for `%s`", userID)
+	} else {
+		ce.Reply("Set nickname of `%s` to %s in your private rooms", userID, nickname)
+	}
+}
+
+var cmdSharedInvites = &commands.FullHandler{
+	Func: fnSharedInvites,
+	Name: "shared-invites",
+	Help: commands.HelpMeta{
+		Section:     commands.HelpSectionChats,
+		Description: "List pending Slack Connect channel invites",
+	},
+	RequiresLogin: true,
+}
+
+func fnSharedInvites(ce *commands.Event) {
+	client := getClientForCommand(ce)
+	if client == nil {
+		return
+	}
+	invites, err := client.ListSharedChannelInvites(ce.Ctx)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to list shared channel invites")
+		ce.Reply("Failed to list shared channel invites: %v", err)
+		return
+	}
+	lines := make([]string, 0, len(invites))
+	for _, invite := range invites {
+		if invite.Direction == "outgoing" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(
+			"* `%s`: #%s from %s (%s), sent %s",
+			invite.Invite.ID,
+			invite.Channel.Name,
+			invite.Invite.InvitingUser.Name,
+			invite.Invite.InvitingTeam.Name,
+			time.Unix(invite.Invite.DateCreated, 0).Format(time.DateOnly),
+		))
+	}
+	if len(lines) == 0 {
+		ce.Reply("No pending shared channel invites")
+		return
+	}
+	ce.Reply("Pending shared channel invites:\n\n%s\n\nUse `$cmdprefix accept-shared-invite <invite ID>` to accept one.", strings.Join(lines, "\n"))
+}
+
+var cmdAcceptSharedInvite = &commands.FullHandler{
+	Func: fnAcceptSharedInvite,
+	Name: "accept-shared-invite",
+	Help: commands.HelpMeta{
+		Section:     commands.HelpSectionChats,
+		Description: "Accept a Slack Connect channel invite and create a portal for the channel",
+		Args:        "<_invite ID_>",
+	},
+	RequiresLogin: true,
+}
+
+func fnAcceptSharedInvite(ce *commands.Event) {
+	if len(ce.Args) == 0 {
+		ce.Reply("**Usage:** `$cmdprefix accept-shared-invite <invite ID>`")
+		return
+	}
+	client := getClientForCommand(ce)
+	if client == nil {
+		return
+	}
+	invites, err := client.ListSharedChannelInvites(ce.Ctx)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to list shared channel invites")
+		ce.Reply("Failed to list shared channel invites: %v", err)
+		return
+	}
+	var invite *SharedChannelInvite
+	for i := range invites {
+		if invites[i].Invite.ID == ce.Args[0] {
+			invite = &invites[i]
+			break
+		}
+	}
+	if invite == nil {
+		ce.Reply("Invite `%s` not found", ce.Args[0])
+		return
+	}
+	channelID, err := client.AcceptSharedInvite(ce.Ctx, invite)
+	if err != nil {
+		ce.Log.Err(err).Str("invite_id", invite.Invite.ID).Msg("Failed to accept shared channel invite")
+		ce.Reply("Failed to accept invite: %v", err)
+		return
+	}
+	if channelID == "" {
+		channelID = invite.Channel.ID
+	}
+	err = client.queueChannelCreate(ce.Ctx, channelID)
+	if err != nil {
+		ce.Log.Err(err).Str("channel_id", channelID).Msg("Failed to queue portal creation for accepted shared channel")
+		ce.Reply("Accepted invite, but failed to create portal: %v", err)
+		return
+	}
+	ce.Reply("Accepted invite to #%s, the portal room will be created shortly", invite.Channel.Name)
+}
+
+var cmdSystemMessages = &commands.FullHandler{
+	Func: fnSystemMessages,
+	Name: "system-messages",
+	Help: commands.HelpMeta{
+		Section:     commands.HelpSectionChats,
+		Description: "Choose whether Slack system messages (joins, leaves, topic changes, etc.) are bridged in this room",
+		Args:        "<show|hide|default>",
+	},
+	RequiresPortal: true,
+	RequiresLogin:  true,
+}
+
+func fnSystemMessages(ce *commands.Event) {
+	meta := ce.Portal.Metadata.(*slackid.PortalMetadata)
+	if len(ce.Args) == 0 {
+		var current string
+		switch {
+		case meta.SuppressSystemMessages == nil:
+			current = "using the bridge default"
+		case *meta.SuppressSystemMessages:
+			current = "hidden"
+		default:
+			current = "shown"
+		}
+		ce.Reply("System messages are currently %s in this room.\n\n**Usage:** `$cmdprefix system-messages <show|hide|default>`", current)
+		return
+	}
+	switch strings.ToLower(ce.Args[0]) {
+	case "show":
+		meta.SuppressSystemMessages = ptr.Ptr(false)
+	case "hide":
+		meta.SuppressSystemMessages = ptr.Ptr(true)
+	case "default":
+		meta.SuppressSystemMessages = nil
+	default:
+		ce.Reply("**Usage:** `$cmdprefix system-messages <show|hide|default>`")
+		return
+	}
+	err := ce.Portal.Save(ce.Ctx)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to save portal after changing system message setting")
+		ce.Reply("Failed to save setting: %v", err)
+		return
+	}
+	ce.React("✅")
+}
+
+var cmdResyncTeamUsers = &commands.FullHandler{
+	Func: fnResyncTeamUsers,
+	Name: "resync-team-users",
+	Help: commands.HelpMeta{
+		Section:     commands.HelpSectionAdmin,
+		Description: "Force a resync of the info of all known users in a Slack workspace",
+		Args:        "[_team ID_]",
+	},
+	RequiresAdmin: true,
+	RequiresLogin: true,
+}
+
+const teamUserResyncBatchSize = 100
+
+func fnResyncTeamUsers(ce *commands.Event) {
+	var client *SlackClient
+	if len(ce.Args) > 0 {
+		teamID := strings.ToUpper(ce.Args[0])
+		for _, userLogin := range ce.User.GetUserLogins() {
+			if loginTeamID, _ := slackid.ParseUserLoginID(userLogin.ID); loginTeamID == teamID {
+				client, _ = userLogin.Client.(*SlackClient)
+				break
+			}
+		}
+		if client == nil || !client.IsLoggedIn() {
+			ce.Reply("You're not logged into the team `%s`", teamID)
+			return
+		}
+	} else if client = getClientForCommand(ce); client == nil {
+		return
+	}
+	ghostIDs, err := client.Main.DB.GetGhostIDsInTeam(ce.Ctx, ce.Bridge.ID, client.TeamID)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to get ghosts in team")
+		ce.Reply("Failed to get users in team: %v", err)
+		return
+	}
+	ce.Reply("Resyncing %d users in %s", len(ghostIDs), client.UserLogin.RemoteName)
+	batch := make(map[string]*bridgev2.Ghost, teamUserResyncBatchSize)
+	for _, ghostID := range ghostIDs {
+		ghost, err := ce.Bridge.GetGhostByID(ce.Ctx, ghostID)
+		if err != nil {
+			ce.Log.Err(err).Str("ghost_id", string(ghostID)).Msg("Failed to get ghost for resync")
+			continue
+		}
+		_, userID := slackid.ParseUserID(ghostID)
+		if !client.IsRealUser || strings.HasPrefix(userID, "B") {
+			// The users cache endpoint is only available for real users and doesn't include bots
+			info, err := client.fetchUserInfo(ce.Ctx, userID, 0, ghost)
+			if err != nil {
+				ce.Log.Err(err).Str("ghost_id", string(ghostID)).Msg("Failed to fetch user info for resync")
+			} else if info != nil {
+				ghost.UpdateInfo(ce.Ctx, info)
+			}
+			continue
+		}
+		batch[userID] = ghost
+		if len(batch) >= teamUserResyncBatchSize {
+			client.syncManyUsers(ce.Ctx, batch, true)
+			batch = make(map[string]*bridgev2.Ghost, teamUserResyncBatchSize)
+		}
+	}
+	if len(batch) > 0 {
+		client.syncManyUsers(ce.Ctx, batch, true)
+	}
+	ce.Reply("Finished resyncing users")
+}
+
+var cmdBridgeBot = &commands.FullHandler{
+	Func: fnBridgeBot,
+	Name: "bridge-bot",
+	Help: commands.HelpMeta{
+		Section:     commands.HelpSectionChats,
+		Description: "Check whether the bridge bot is in this room, or bring it back if it has left. The bot is listed as a service member, so clients hide it from room summaries.",
+		Args:        "<stay|leave>",
+	},
+	RequiresAdmin:  true,
+	RequiresPortal: true,
+	RequiresLogin:  true,
+}
+
+func fnBridgeBot(ce *commands.Event) {
+	if len(ce.Args) == 0 {
+		current := "is in"
+		if mxConnector, ok := ce.Bridge.Matrix.(*matrix.Connector); ok && !mxConnector.StateStore.IsInRoom(ce.Ctx, ce.Portal.MXID, ce.Bot.GetMXID()) {
+			current = "isn't in"
+		}
+		ce.Reply("The bridge bot currently %s this room.\n\n**Usage:** `$cmdprefix bridge-bot <stay|leave>`", current)
+		return
+	}
+	switch strings.ToLower(ce.Args[0]) {
+	case "stay":
+		err := ensureBridgeBotJoined(ce)
+		if err != nil {
+			ce.Log.Err(err).Msg("Failed to rejoin bridge bot to portal")
+			ce.Reply("Failed to rejoin the bridge bot, try inviting it manually: %v", err)
+			return
+		}
+		ce.React("✅")
+	case "leave":
+		// Ghost invites and room name, topic, avatar and power level changes all go through the bot,
+		// so the room would silently stop updating without it.
+		ce.Reply("The bridge bot can't leave rooms, as it's needed for inviting Slack users and updating the room info. " +
+			"It's listed as a service member, so clients already hide it from room summaries.")
+	default:
+		ce.Reply("**Usage:** `$cmdprefix bridge-bot <stay|leave>`")
+	}
+}
+
+// ensureBridgeBotJoined invites the bridge bot back using the user's own ghost, as the bot
+// can't join on its own after leaving an invite-only room.
+func ensureBridgeBotJoined(ce *commands.Event) error {
+	client := getClientForCommand(ce)
+	if client == nil {
+		return fmt.Errorf("not logged in")
+	}
+	ghost, err := ce.Bridge.GetGhostByID(ce.Ctx, slackid.MakeUserID(client.TeamID, client.UserID))
+	if err != nil {
+		return fmt.Errorf("failed to get own ghost: %w", err)
+	}
+	err = ghost.Intent.EnsureInvited(ce.Ctx, ce.Portal.MXID, ce.Bot.GetMXID())
+	if err != nil {
+		return fmt.Errorf("failed to invite bot: %w", err)
+	}
+	return ce.Bot.EnsureJoined(ce.Ctx, ce.Portal.MXID)
+}
+
+var cmdCleanupOrphans = &commands.FullHandler{
+	Func: fnCleanupOrphans,
+	Name: "cleanup-orphans",
+	Help: commands.HelpMeta{
+		Section:     commands.HelpSectionAdmin,
+		Description: "List portals whose Matrix rooms no longer exist, and optionally delete them from the database",
+		Args:        "[--delete]",
+	},
+	RequiresAdmin: true,
+}
+
+func fnCleanupOrphans(ce *commands.Event) {
+	mxConnector, ok := ce.Bridge.Matrix.(*matrix.Connector)
+	if !ok {
+		ce.Reply("This command is only supported with the standard Matrix connector")
+		return
+	}
+	shouldDelete := len(ce.Args) > 0 && ce.Args[0] == "--delete"
+	portals, err := ce.Bridge.GetAllPortalsWithMXID(ce.Ctx)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to get portals")
+		ce.Reply("Failed to get portals: %v", err)
+		return
+	}
+	joinedRooms, err := mxConnector.Bot.JoinedRooms(ce.Ctx)
+	if err != nil {
+		ce.Log.Err(err).Msg("Failed to get joined rooms")
+		ce.Reply("Failed to get rooms the bridge bot is in: %v", err)
+		return
+	}
+	joined := make(map[id.RoomID]struct{}, len(joinedRooms.JoinedRooms))
+	for _, roomID := range joinedRooms.JoinedRooms {
+		joined[roomID] = struct{}{}
+	}
+	var orphans, inaccessible []*bridgev2.Portal
+	for _, portal := range portals {
+		_, isJoined := joined[portal.MXID]
+		var summaryErr error
+		if !isJoined {
+			_, summaryErr = mxConnector.Bot.GetRoomSummary(ce.Ctx, portal.MXID.String())
+		}
+		wasJoined := mxConnector.StateStore.IsInRoom(ce.Ctx, portal.MXID, mxConnector.Bot.UserID)
+		switch classifyPortalRoom(isJoined, wasJoined, summaryErr) {
+		case portalRoomOrphaned:
+			orphans = append(orphans, portal)
+		case portalRoomInaccessible:
+			inaccessible = append(inaccessible, portal)
+		case portalRoomUnknown:
+			ce.Log.Err(summaryErr).Stringer("room_id", portal.MXID).Msg("Failed to check if portal room exists")
+		}
+	}
+	formatPortals := func(portals []*bridgev2.Portal) string {
+		lines := make([]string, len(portals))
+		for i, portal := range portals {
+			lines[i] = fmt.Sprintf("* `%s` (%s): %s", portal.ID, portal.MXID, portal.Name)
+		}
+		return strings.Join(lines, "\n")
+	}
+	if len(inaccessible) > 0 {
+		ce.Reply("The bridge bot can't access %d portal rooms. They may still exist, so they won't be deleted:\n\n%s",
+			len(inaccessible), formatPortals(inaccessible))
+	}
+	if len(orphans) == 0 {
+		ce.Reply("No orphaned portals found")
+		return
+	}
+	if !shouldDelete {
+		ce.Reply("Found %d orphaned portals:\n\n%s\n\nUse `$cmdprefix cleanup-orphans --delete` to delete them from the database",
+			len(orphans), formatPortals(orphans))
+		return
+	}
+	var deleted int
+	for _, portal := range orphans {
+		err = portal.Delete(ce.Ctx)
+		if err != nil {
+			ce.Log.Err(err).Str("portal_id", string(portal.ID)).Msg("Failed to delete orphaned portal")
+			ce.Reply("Failed to delete portal `%s`: %v", portal.ID, err)
+		} else {
+			deleted++
+		}
+	}
+	ce.Reply("Deleted %d of %d orphaned portals:\n\n%s", deleted, len(orphans), formatPortals(orphans))
+}
+
+type portalRoomStatus int
+
+const (
+	portalRoomExists portalRoomStatus = iota
+	portalRoomOrphaned
+	portalRoomInaccessible
+	portalRoomUnknown
+)
+
+// classifyPortalRoom decides whether a portal room still exists. Homeservers return M_FORBIDDEN both for deleted
+// rooms and for rooms the bot isn't in, so errors alone aren't enough. A room is only considered deleted if the state
+// store still thinks the bot is joined (i.e. the bot never received a leave or kick), but the homeserver no longer
+// lists it in the bot's joined rooms and the room summary can't be found either.
+func classifyPortalRoom(isJoined, wasJoined bool, summaryErr error) portalRoomStatus {
+	switch {
+	case isJoined:
+		return portalRoomExists
+	case summaryErr == nil:
+		// The room summary is still available, so the room exists even though the bot isn't in it
+		return portalRoomInaccessible
+	case !errors.Is(summaryErr, mautrix.MNotFound) && !errors.Is(summaryErr, mautrix.MForbidden):
+		return portalRoomUnknown
+	case !wasJoined:
+		// The bot left or was kicked, so the room may still exist
+		return portalRoomInaccessible
+	default:
+		return portalRoomOrphaned
+	}
+}
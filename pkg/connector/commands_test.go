@@ -0,0 +1,40 @@
+// mautrix-slack - A Matrix-Slack puppeting bridge.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package connector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"maunium.net/go/mautrix"
+)
+
+func TestClassifyPortalRoom(t *testing.T) {
+	forbidden := mautrix.HTTPError{RespError: &mautrix.RespError{ErrCode: "M_FORBIDDEN", StatusCode: 403}}
+	notFound := mautrix.HTTPError{RespError: &mautrix.RespError{ErrCode: "M_NOT_FOUND", StatusCode: 404}}
+
+	assert.Equal(t, portalRoomExists, classifyPortalRoom(true, true, nil))
+	// Deleted rooms return M_FORBIDDEN, but the bot never saw itself leave
+	assert.Equal(t, portalRoomOrphaned, classifyPortalRoom(false, true, forbidden))
+	assert.Equal(t, portalRoomOrphaned, classifyPortalRoom(false, true, notFound))
+	// The bot was kicked, so the room may still exist
+	assert.Equal(t, portalRoomInaccessible, classifyPortalRoom(false, false, forbidden))
+	// The summary is still available, so the room definitely exists
+	assert.Equal(t, portalRoomInaccessible, classifyPortalRoom(false, true, nil))
+	assert.Equal(t, portalRoomUnknown, classifyPortalRoom(false, true, errors.New("connection refused")))
+}
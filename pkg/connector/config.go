@@ -18,12 +18,18 @@ package connector
 
 import (
 	_ "embed"
+	"fmt"
+	"slices"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/slack-go/slack"
 	up "go.mau.fi/util/configupgrade"
 	"gopkg.in/yaml.v3"
+	"maunium.net/go/mautrix/bridgev2"
+
+	"go.mau.fi/mautrix-slack/pkg/emoji"
 )
 
 //go:embed example-config.yaml
@@ -34,19 +40,51 @@ type Config struct {
 	ChannelNameTemplate string `yaml:"channel_name_template"`
 	TeamNameTemplate    string `yaml:"team_name_template"`
 
-	CustomEmojiReactions        bool `yaml:"custom_emoji_reactions"`
-	WorkspaceAvatarInRooms      bool `yaml:"workspace_avatar_in_rooms"`
-	ParticipantSyncCount        int  `yaml:"participant_sync_count"`
-	ParticipantSyncOnlyOnCreate bool `yaml:"participant_sync_only_on_create"`
-	MuteChannelsByDefault       bool `yaml:"mute_channels_by_default"`
+	RelayUsernameTemplate string `yaml:"relay_username_template"`
+
+	CustomEmojiReactions        bool            `yaml:"custom_emoji_reactions"`
+	WorkspaceAvatarInRooms      bool            `yaml:"workspace_avatar_in_rooms"`
+	ParticipantSyncCount        int             `yaml:"participant_sync_count"`
+	ParticipantSyncOnlyOnCreate bool            `yaml:"participant_sync_only_on_create"`
+	MuteChannelsByDefault       bool            `yaml:"mute_channels_by_default"`
+	SuppressSystemMessages      bool            `yaml:"suppress_system_messages"`
+	SuppressSlackPrompts        bool            `yaml:"suppress_slack_prompts"`
+	TimestampSource             TimestampSource `yaml:"timestamp_source"`
+	Timezone                    string          `yaml:"timezone"`
+	RoomMentionMemberLimit      int             `yaml:"room_mention_member_limit"`
+	MediaUploadRetries          int             `yaml:"media_upload_retries"`
+	DeletePortalOnLeave         bool            `yaml:"delete_portal_on_leave"`
+	EmojiPack                   bool            `yaml:"emoji_pack"`
+	RedactEmptyEdits            bool            `yaml:"redact_empty_edits"`
+	HotMessageThreshold         int             `yaml:"hot_message_threshold"`
+	MarkAppMessages             bool            `yaml:"mark_app_messages"`
+	DedupClientMsgID            bool            `yaml:"dedup_client_msg_id"`
+	QuoteAttribution            bool            `yaml:"quote_attribution"`
+
+	ProfileFieldIdentifiers []string `yaml:"profile_field_identifiers"`
+	DisableTypingTeams      []string `yaml:"disable_typing_teams"`
+
+	StatusPresence      bool              `yaml:"status_presence"`
+	StatusEmojiMessages map[string]string `yaml:"status_emoji_messages"`
 
 	Backfill BackfillConfig `yaml:"backfill"`
 
 	displaynameTemplate *template.Template `yaml:"-"`
 	channelNameTemplate *template.Template `yaml:"-"`
 	teamNameTemplate    *template.Template `yaml:"-"`
+
+	relayUsernameTemplate *template.Template `yaml:"-"`
+
+	timezone *time.Location `yaml:"-"`
 }
 
+type TimestampSource string
+
+const (
+	TimestampSourceSlack   TimestampSource = "slack"
+	TimestampSourceReceive TimestampSource = "receive"
+)
+
 type BackfillConfig struct {
 	ConversationCount int  `yaml:"conversation_count"`
 	Enabled           bool `yaml:"enabled"`
@@ -72,6 +110,21 @@ func (c *Config) UnmarshalYAML(node *yaml.Node) error {
 	if err != nil {
 		return err
 	}
+	c.relayUsernameTemplate, err = template.New("relay_username").Parse(c.RelayUsernameTemplate)
+	if err != nil {
+		return err
+	}
+	switch c.TimestampSource {
+	case "", TimestampSourceSlack, TimestampSourceReceive:
+	default:
+		return fmt.Errorf("invalid timestamp_source %q", c.TimestampSource)
+	}
+	if c.Timezone != "" {
+		c.timezone, err = time.LoadLocation(c.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -120,6 +173,63 @@ func (c *Config) FormatTeamName(params *slack.TeamInfo) string {
 	return executeTemplate(c.teamNameTemplate, params)
 }
 
+func (c *Config) getProfileFieldIdentifiers(profile *slack.UserProfile) (identifiers []string) {
+	customFields := profile.Fields.ToMap()
+	for _, field := range c.ProfileFieldIdentifiers {
+		var value string
+		switch field {
+		case "title":
+			value = profile.Title
+		case "phone":
+			value = profile.Phone
+		default:
+			value = customFields[field].Value
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			identifiers = append(identifiers, fmt.Sprintf("slack-%s:%s", field, value))
+		}
+	}
+	return
+}
+
+// formatStatusMessage returns the presence status message for the given Slack profile and the time when it expires.
+// The status text is preferred, with the status_emoji_messages mapping used as a fallback for statuses that only
+// have an emoji. Expired statuses are treated as empty.
+func (c *Config) formatStatusMessage(profile *slack.UserProfile) (string, time.Time) {
+	var expiry time.Time
+	if profile.StatusExpiration > 0 {
+		expiry = time.Unix(int64(profile.StatusExpiration), 0)
+		if time.Now().After(expiry) {
+			return "", time.Time{}
+		}
+	}
+	text := strings.TrimSpace(profile.StatusText)
+	if text == "" {
+		text = c.StatusEmojiMessages[strings.Trim(profile.StatusEmoji, ":")]
+	}
+	if text == "" {
+		return "", time.Time{}
+	} else if unicode := emoji.GetUnicode(profile.StatusEmoji); unicode != "" {
+		return unicode + " " + text, expiry
+	}
+	return text, expiry
+}
+
+func (c *Config) isTypingDisabled(teamID string) bool {
+	return slices.Contains(c.DisableTypingTeams, teamID)
+}
+
+func (c *Config) FormatRelayUsername(sender *bridgev2.OrigSender) string {
+	if c.relayUsernameTemplate == nil {
+		return sender.FormattedName
+	}
+	name := executeTemplate(c.relayUsernameTemplate, sender)
+	if name == "" {
+		return sender.FormattedName
+	}
+	return name
+}
+
 func (s *SlackConnector) GetConfig() (example string, data any, upgrader up.Upgrader) {
 	return ExampleConfig, &s.Config, up.SimpleUpgrader(upgradeConfig)
 }
@@ -128,10 +238,28 @@ func upgradeConfig(helper up.Helper) {
 	helper.Copy(up.Str, "displayname_template")
 	helper.Copy(up.Str, "channel_name_template")
 	helper.Copy(up.Str, "team_name_template")
+	helper.Copy(up.Str, "relay_username_template")
 	helper.Copy(up.Bool, "custom_emoji_reactions")
 	helper.Copy(up.Bool, "workspace_avatar_in_rooms")
 	helper.Copy(up.Int, "participant_sync_count")
 	helper.Copy(up.Bool, "participant_sync_only_on_create")
 	helper.Copy(up.Bool, "mute_channels_by_default")
+	helper.Copy(up.Bool, "suppress_system_messages")
+	helper.Copy(up.Bool, "suppress_slack_prompts")
+	helper.Copy(up.Str, "timestamp_source")
+	helper.Copy(up.Str, "timezone")
+	helper.Copy(up.Int, "room_mention_member_limit")
+	helper.Copy(up.Int, "media_upload_retries")
+	helper.Copy(up.Bool, "delete_portal_on_leave")
+	helper.Copy(up.Bool, "emoji_pack")
+	helper.Copy(up.Bool, "redact_empty_edits")
+	helper.Copy(up.Int, "hot_message_threshold")
+	helper.Copy(up.Bool, "mark_app_messages")
+	helper.Copy(up.Bool, "dedup_client_msg_id")
+	helper.Copy(up.Bool, "quote_attribution")
+	helper.Copy(up.List, "profile_field_identifiers")
+	helper.Copy(up.List, "disable_typing_teams")
+	helper.Copy(up.Bool, "status_presence")
+	helper.Copy(up.Map, "status_emoji_messages")
 	helper.Copy(up.Int, "backfill", "conversation_count")
 }
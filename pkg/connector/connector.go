@@ -18,8 +18,11 @@ package connector
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/networkid"
 
 	"go.mau.fi/mautrix-slack/pkg/connector/slackdb"
 	"go.mau.fi/mautrix-slack/pkg/msgconv"
@@ -30,6 +33,9 @@ type SlackConnector struct {
 	Config  Config
 	DB      *slackdb.SlackDB
 	MsgConv *msgconv.MessageConverter
+
+	statusTimers map[networkid.UserID]*time.Timer
+	statusLock   sync.Mutex
 }
 
 var (
@@ -41,7 +47,16 @@ func (s *SlackConnector) Init(bridge *bridgev2.Bridge) {
 	s.br = bridge
 	s.DB = slackdb.New(bridge.DB.Database, bridge.Log.With().Str("db_section", "slack").Logger())
 	s.MsgConv = msgconv.New(bridge, s.DB)
+	s.MsgConv.FormatRelayUsername = s.Config.FormatRelayUsername
+	s.MsgConv.RoomMentionMemberLimit = s.Config.RoomMentionMemberLimit
+	s.MsgConv.MediaUploadRetries = s.Config.MediaUploadRetries
+	s.MsgConv.Timezone = s.Config.timezone
+	s.MsgConv.RedactEmptyEdits = s.Config.RedactEmptyEdits
+	s.MsgConv.MarkAppMessages = s.Config.MarkAppMessages
+	s.MsgConv.QuoteAttribution = s.Config.QuoteAttribution
 	bridge.Config.PersonalFilteringSpaces = false
+	s.statusTimers = make(map[networkid.UserID]*time.Timer)
+	s.registerCommands()
 }
 
 func (s *SlackConnector) SetMaxFileSize(maxSize int64) {
@@ -18,14 +18,19 @@ package connector
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/slack-go/slack"
 	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/mautrix-slack/pkg/connector/slackdb"
@@ -61,6 +66,11 @@ func (s *SlackClient) handleEmojiChange(ctx context.Context, evt *slack.EmojiCha
 		} else {
 			log.Debug().Str("old_id", evt.OldName).Str("new_name", evt.NewName).Msg("Handled emoji rename")
 		}
+		// Reactions use the shortcode as the emoji ID, so they have to be updated for removals to work
+		err = s.Main.DB.Emoji.RenameReactions(ctx, s.Main.br.ID, s.TeamID, evt.OldName, evt.NewName)
+		if err != nil {
+			log.Err(err).Msg("Failed to update emoji ID of existing reactions")
+		}
 	default:
 		log.Warn().Msg("Unknown emoji change subtype, resyncing emojis")
 		err := s.syncEmojis(ctx, false)
@@ -68,6 +78,7 @@ func (s *SlackClient) handleEmojiChange(ctx context.Context, evt *slack.EmojiCha
 			log.Err(err).Msg("Failed to resync emojis")
 		}
 	}
+	s.updateEmojiPack(ctx)
 }
 
 func (s *SlackClient) addEmoji(ctx context.Context, emojiName, emojiValue string) *slackdb.Emoji {
@@ -169,6 +180,152 @@ func (s *SlackClient) SyncEmojis(ctx context.Context) {
 	if err != nil {
 		zerolog.Ctx(ctx).Err(err).Msg("Failed to sync emojis")
 	}
+	s.updateEmojiPack(ctx)
+}
+
+var StateImagePack = event.Type{Type: "im.ponies.room_emotes", Class: event.StateEventType}
+
+type imagePackImage struct {
+	URL  id.ContentURIString `json:"url"`
+	Body string              `json:"body,omitempty"`
+}
+
+type imagePackInfo struct {
+	DisplayName string   `json:"display_name,omitempty"`
+	AvatarURL   string   `json:"avatar_url,omitempty"`
+	Usage       []string `json:"usage,omitempty"`
+}
+
+type imagePackContent struct {
+	Images map[string]*imagePackImage `json:"images"`
+	Pack   imagePackInfo              `json:"pack"`
+}
+
+const (
+	emojiPackStateKey = "fi.mau.slack.emojis"
+	// State events are limited to 64 KiB, so large emoji lists are split into multiple packs
+	emojiPackShardSize = 250
+)
+
+// updateEmojiPack schedules an update of the MSC2545 image packs containing the custom emojis of the team.
+// The pack is updated in the background, as reuploading emojis can take a long time and mustn't hold the emoji lock.
+func (s *SlackClient) updateEmojiPack(ctx context.Context) {
+	if !s.Main.Config.EmojiPack {
+		return
+	}
+	log := zerolog.Ctx(ctx).With().Str("action", "update emoji pack").Logger()
+	go s.syncEmojiPack(log.WithContext(context.Background()))
+}
+
+func (s *SlackClient) syncEmojiPack(ctx context.Context) {
+	s.emojiPackLock.Lock()
+	defer s.emojiPackLock.Unlock()
+	log := zerolog.Ctx(ctx)
+	teamPortal, err := s.Main.br.GetExistingPortalByKey(ctx, s.makeTeamPortalKey(s.TeamID))
+	if err != nil {
+		log.Err(err).Msg("Failed to get team portal")
+		return
+	} else if teamPortal == nil || teamPortal.MXID == "" {
+		log.Debug().Msg("Not updating emoji pack as team space doesn't exist")
+		return
+	}
+	emojis, err := s.Main.DB.Emoji.GetAllInTeam(ctx, s.TeamID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get emojis from database")
+		return
+	}
+	slices.SortFunc(emojis, func(a, b *slackdb.Emoji) int {
+		return strings.Compare(a.EmojiID, b.EmojiID)
+	})
+	byID := make(map[string]*slackdb.Emoji, len(emojis))
+	for _, dbEmoji := range emojis {
+		byID[dbEmoji.EmojiID] = dbEmoji
+	}
+	var shards []map[string]*imagePackImage
+	for _, dbEmoji := range emojis {
+		target := dbEmoji
+		if dbEmoji.Alias != "" {
+			target = byID[dbEmoji.Alias]
+			if target == nil {
+				// Aliases of standard emojis don't have an image
+				continue
+			}
+		}
+		if target.ImageMXC == "" {
+			target.ImageMXC, err = reuploadEmoji(ctx, s.Main.br.Bot, target.Value)
+			if err != nil {
+				log.Err(err).Str("emoji_id", target.EmojiID).Msg("Failed to reupload emoji")
+				continue
+			}
+			err = s.Main.DB.Emoji.SaveMXC(ctx, target)
+			if err != nil {
+				log.Err(err).Str("emoji_id", target.EmojiID).Msg("Failed to save reuploaded emoji")
+			}
+		}
+		if len(shards) == 0 || len(shards[len(shards)-1]) >= emojiPackShardSize {
+			shards = append(shards, make(map[string]*imagePackImage, emojiPackShardSize))
+		}
+		shards[len(shards)-1][dbEmoji.EmojiID] = &imagePackImage{
+			URL:  target.ImageMXC,
+			Body: fmt.Sprintf(":%s:", dbEmoji.EmojiID),
+		}
+	}
+	if len(shards) == 0 {
+		shards = append(shards, map[string]*imagePackImage{})
+	}
+	sentKeys := make(map[string]struct{}, len(shards))
+	for i, images := range shards {
+		stateKey := emojiPackStateKey
+		displayName := teamPortal.Name
+		if i > 0 {
+			stateKey = fmt.Sprintf("%s.%d", emojiPackStateKey, i)
+			displayName = fmt.Sprintf("%s (%d)", teamPortal.Name, i+1)
+		}
+		sentKeys[stateKey] = struct{}{}
+		s.sendEmojiPackShard(ctx, teamPortal.MXID, stateKey, &imagePackContent{
+			Images: images,
+			Pack: imagePackInfo{
+				DisplayName: displayName,
+				AvatarURL:   string(teamPortal.AvatarMXC),
+				Usage:       []string{"emoticon"},
+			},
+		})
+	}
+	for stateKey := range s.emojiPackHashes {
+		if _, ok := sentKeys[stateKey]; !ok {
+			// The pack has shrunk, so empty the shards that are no longer needed
+			s.sendEmojiPackShard(ctx, teamPortal.MXID, stateKey, &imagePackContent{Images: map[string]*imagePackImage{}})
+		}
+	}
+}
+
+// sendEmojiPackShard sends one emoji pack state event, unless the same content was already sent.
+// The caller must hold the emoji pack lock.
+func (s *SlackClient) sendEmojiPackShard(ctx context.Context, roomID id.RoomID, stateKey string, content *imagePackContent) {
+	log := zerolog.Ctx(ctx).With().Str("state_key", stateKey).Logger()
+	contentJSON, err := json.Marshal(content)
+	if err != nil {
+		log.Err(err).Msg("Failed to marshal emoji pack")
+		return
+	}
+	hash := sha256.Sum256(contentJSON)
+	if s.emojiPackHashes[stateKey] == hash {
+		log.Debug().Msg("Not sending emoji pack as it hasn't changed")
+		return
+	}
+	_, err = s.Main.br.Bot.SendState(ctx, roomID, StateImagePack, stateKey, &event.Content{
+		Parsed: content,
+	}, time.Time{})
+	if err != nil {
+		log.Err(err).Msg("Failed to send emoji pack")
+		return
+	}
+	if len(content.Images) == 0 && stateKey != emojiPackStateKey {
+		delete(s.emojiPackHashes, stateKey)
+	} else {
+		s.emojiPackHashes[stateKey] = hash
+	}
+	log.Debug().Int("emoji_count", len(content.Images)).Msg("Updated emoji pack")
 }
 
 func (s *SlackClient) syncEmojis(ctx context.Context, onlyIfCountMismatch bool) error {
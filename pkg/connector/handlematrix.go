@@ -60,6 +60,7 @@ func (s *SlackClient) HandleMatrixMessage(ctx context.Context, msg *bridgev2.Mat
 	if err != nil {
 		return nil, err
 	}
+	s.clearBarrierBlocked(channelID)
 	if timestamp == "" {
 		return &bridgev2.MatrixMessageResponse{Pending: true}, nil
 	}
@@ -178,10 +179,19 @@ func (s *SlackClient) HandleMatrixReaction(ctx context.Context, msg *bridgev2.Ma
 	if !ok {
 		return nil, errors.New("invalid message ID")
 	}
-	err = s.Client.AddReactionContext(ctx, string(msg.PreHandleResp.EmojiID), slack.ItemRef{
+	emojiID := string(msg.PreHandleResp.EmojiID)
+	s.expectReactionEcho(channelID, messageID, emojiID, true)
+	err = s.Client.AddReactionContext(ctx, emojiID, slack.ItemRef{
 		Channel:   channelID,
 		Timestamp: messageID,
 	})
+	if err != nil {
+		s.cancelReactionEcho(channelID, messageID, emojiID, true)
+		if err.Error() == "already_reacted" {
+			// The reaction already exists on Slack (e.g. the removal hadn't gone through yet), so the states match
+			err = nil
+		}
+	}
 	return
 }
 
@@ -193,12 +203,17 @@ func (s *SlackClient) HandleMatrixReactionRemove(ctx context.Context, msg *bridg
 	if !ok {
 		return errors.New("invalid message ID")
 	}
-	err := s.Client.RemoveReactionContext(ctx, string(msg.TargetReaction.EmojiID), slack.ItemRef{
+	emojiID := string(msg.TargetReaction.EmojiID)
+	s.expectReactionEcho(channelID, messageID, emojiID, false)
+	err := s.Client.RemoveReactionContext(ctx, emojiID, slack.ItemRef{
 		Channel:   channelID,
 		Timestamp: messageID,
 	})
-	if err != nil && err.Error() != "reaction" {
-		return err
+	if err != nil {
+		s.cancelReactionEcho(channelID, messageID, emojiID, false)
+		if err.Error() != "no_reaction" {
+			return err
+		}
 	}
 	return nil
 }
@@ -232,7 +247,7 @@ func (s *SlackClient) HandleMatrixReadReceipt(ctx context.Context, msg *bridgev2
 func (s *SlackClient) HandleMatrixTyping(ctx context.Context, msg *bridgev2.MatrixTyping) error {
 	if s.Client == nil {
 		return bridgev2.ErrNotLoggedIn
-	} else if !s.IsRealUser {
+	} else if !s.IsRealUser || s.Main.Config.isTypingDisabled(s.TeamID) {
 		return nil
 	}
 	_, channelID := slackid.ParsePortalID(msg.Portal.ID)
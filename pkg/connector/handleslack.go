@@ -17,8 +17,11 @@
 package connector
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -26,12 +29,14 @@ import (
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"github.com/slack-go/slack/socketmode"
+	"go.mau.fi/util/ptr"
 	"maunium.net/go/mautrix/bridge/status"
 	"maunium.net/go/mautrix/bridgev2"
 	"maunium.net/go/mautrix/bridgev2/database"
 	"maunium.net/go/mautrix/bridgev2/networkid"
 	"maunium.net/go/mautrix/event"
 
+	"go.mau.fi/mautrix-slack/pkg/msgconv"
 	"go.mau.fi/mautrix-slack/pkg/slackid"
 )
 
@@ -76,6 +81,7 @@ func (s *SlackClient) HandleSlackEvent(rawEvt any) {
 			Msg("Got RTM error")
 	case *slack.HelloEvent:
 		log.Debug().Msg("Received hello event from websocket (now really connected)")
+		s.lastConnect.Store(time.Now().UnixNano())
 		s.UserLogin.BridgeState.Send(status.BridgeState{StateEvent: status.StateConnected})
 	case *slack.InvalidAuthEvent:
 		s.invalidateSession(ctx, status.BridgeState{
@@ -89,7 +95,7 @@ func (s *SlackClient) HandleSlackEvent(rawEvt any) {
 			Error:      status.BridgeStateErrorCode(fmt.Sprintf("slack-rtm-error-%d", evt.Code)),
 			Message:    fmt.Sprintf("%d: %s", evt.Code, evt.Msg),
 		})
-	case *slack.MessageEvent, *slack.ReactionAddedEvent, *slack.ReactionRemovedEvent,
+	case *slack.MessageEvent, *rtmMessageEvent, *slack.ReactionAddedEvent, *slack.ReactionRemovedEvent,
 		*slack.UserTypingEvent, *slack.ChannelMarkedEvent, *slack.IMMarkedEvent, *slack.GroupMarkedEvent,
 		*slack.ChannelJoinedEvent, *slack.ChannelLeftEvent, *slack.GroupJoinedEvent, *slack.GroupLeftEvent,
 		*slack.MemberJoinedChannelEvent, *slack.MemberLeftChannelEvent,
@@ -99,6 +105,9 @@ func (s *SlackClient) HandleSlackEvent(rawEvt any) {
 			log.Err(err).Msg("Failed to wrap Slack event")
 		} else if wrapped != nil {
 			s.UserLogin.Bridge.QueueRemoteEvent(s.UserLogin, wrapped)
+			if notice := makeRenameNotice(wrapped); notice != nil {
+				s.UserLogin.Bridge.QueueRemoteEvent(s.UserLogin, notice)
+			}
 		}
 	case *slack.EmojiChangedEvent:
 		go s.handleEmojiChange(ctx, evt)
@@ -126,6 +135,7 @@ func (s *SlackClient) HandleSocketModeEvent(evt socketmode.Event) {
 	case socketmode.EventTypeConnectionError:
 		s.UserLogin.BridgeState.Send(status.BridgeState{StateEvent: status.StateTransientDisconnect, Error: "slack-socketmode-connection-error"})
 	case socketmode.EventTypeConnected:
+		s.lastConnect.Store(time.Now().UnixNano())
 		s.UserLogin.BridgeState.Send(status.BridgeState{StateEvent: status.StateConnected})
 	case socketmode.EventTypeEventsAPI:
 		eaEvt, ok := evt.Data.(slackevents.EventsAPIEvent)
@@ -134,7 +144,7 @@ func (s *SlackClient) HandleSocketModeEvent(evt socketmode.Event) {
 			return
 		}
 		if eaEvt.Type == slackevents.CallbackEvent {
-			s.HandleSlackEvent(eaEvt.InnerEvent.Data)
+			s.HandleSlackEvent(convertEventsAPIEvent(eaEvt.InnerEvent.Data))
 		}
 	case socketmode.EventTypeInteractive:
 		//callback, ok := evt.Data.(slack.InteractionCallback)
@@ -146,13 +156,53 @@ func (s *SlackClient) HandleSocketModeEvent(evt socketmode.Event) {
 	}
 }
 
+// convertEventsAPIEvent converts Events API payloads to the equivalent RTM event types where the structure differs.
+func convertEventsAPIEvent(data any) any {
+	switch evt := data.(type) {
+	case *slackevents.ReactionAddedEvent:
+		return &slack.ReactionAddedEvent{
+			Type:           evt.Type,
+			User:           evt.User,
+			ItemUser:       evt.ItemUser,
+			Item:           convertEventsAPIReactionItem(evt.Item),
+			Reaction:       evt.Reaction,
+			EventTimestamp: evt.EventTimestamp,
+		}
+	case *slackevents.ReactionRemovedEvent:
+		return &slack.ReactionRemovedEvent{
+			Type:           evt.Type,
+			User:           evt.User,
+			ItemUser:       evt.ItemUser,
+			Item:           convertEventsAPIReactionItem(evt.Item),
+			Reaction:       evt.Reaction,
+			EventTimestamp: evt.EventTimestamp,
+		}
+	default:
+		return data
+	}
+}
+
+func convertEventsAPIReactionItem(item slackevents.Item) slack.ReactionItem {
+	return slack.ReactionItem{
+		Type:      item.Type,
+		Channel:   item.Channel,
+		Timestamp: item.Timestamp,
+	}
+}
+
 func (s *SlackClient) handleUserChange(ctx context.Context, user *slack.User) {
 	ghost, err := s.Main.br.GetGhostByID(ctx, slackid.MakeUserID(s.TeamID, user.ID))
 	if err != nil {
 		zerolog.Ctx(ctx).Err(err).Msg("Failed to get ghost")
 		return
 	}
+	oldName, oldAvatarMXC := ghost.Name, ghost.AvatarMXC
 	ghost.UpdateInfo(ctx, s.wrapUserInfo(user.ID, user, nil, ghost))
+	if ghost.Name == oldName && ghost.AvatarMXC == oldAvatarMXC && s.hasNicknameOverride(user.ID) {
+		// If the profile change was already synced through another login, wrapUserInfo
+		// won't have noticed it, so reapply the override here.
+		s.applyNicknameOverride(ctx, ghost)
+	}
 }
 
 func (s *SlackClient) handleUserInvalidated(ctx context.Context, userID string) {
@@ -174,10 +224,13 @@ func (s *SlackClient) wrapEvent(ctx context.Context, rawEvt any) (bridgev2.Remot
 	var metaErr error
 	var wrapped bridgev2.RemoteEvent
 	switch evt := rawEvt.(type) {
-	case *slack.MessageEvent:
-		if evt.SubType == slack.MsgSubTypeMessageChanged && evt.SubMessage.SubType == "huddle_thread" {
-			return nil, nil
+	case *rtmMessageEvent:
+		wrapped, err := s.wrapEvent(ctx, &evt.MessageEvent)
+		if msg, ok := wrapped.(*SlackMessage); ok {
+			msg.Extras, msg.SubExtras, msg.PrevExtras = evt.Extras, evt.SubExtras, evt.PrevExtras
 		}
+		return wrapped, err
+	case *slack.MessageEvent:
 		sender := evt.User
 		if sender == "" {
 			sender = evt.BotID
@@ -202,9 +255,16 @@ func (s *SlackClient) wrapEvent(ctx context.Context, rawEvt any) (bridgev2.Remot
 			SlackEventMeta: &meta,
 			Data:           evt,
 			Client:         s,
+			ReceivedAt:     time.Now(),
 		}
 
 	case *slack.ReactionAddedEvent:
+		if evt.User == "" {
+			return nil, fmt.Errorf("reaction event is missing reactor")
+		} else if s.isOwnReactionEcho(evt.User, evt.Reaction, true, evt.Item) {
+			return nil, nil
+		}
+		// The sender is always the reactor (user), never the author of the target message (item_user)
 		meta, metaErr = s.makeEventMeta(ctx, evt.Item.Channel, nil, evt.User, evt.EventTimestamp)
 		var err error
 		wrapped, err = s.wrapReaction(ctx, &meta, evt.Reaction, true, evt.Item)
@@ -212,10 +272,18 @@ func (s *SlackClient) wrapEvent(ctx context.Context, rawEvt any) (bridgev2.Remot
 			return nil, fmt.Errorf("failed to get reaction info: %w", err)
 		}
 	case *slack.ReactionRemovedEvent:
+		if evt.User == "" {
+			return nil, fmt.Errorf("reaction removal event is missing reactor")
+		} else if s.isOwnReactionEcho(evt.User, evt.Reaction, false, evt.Item) {
+			return nil, nil
+		}
 		meta, metaErr = s.makeEventMeta(ctx, evt.Item.Channel, nil, evt.User, evt.EventTimestamp)
 		wrapped, _ = s.wrapReaction(ctx, &meta, evt.Reaction, false, evt.Item)
 
 	case *slack.UserTypingEvent:
+		if s.Main.Config.isTypingDisabled(s.TeamID) {
+			return nil, nil
+		}
 		meta, metaErr = s.makeEventMeta(ctx, evt.Channel, nil, evt.User, "")
 		wrapped = wrapTyping(&meta)
 
@@ -238,26 +306,38 @@ func (s *SlackClient) wrapEvent(ctx context.Context, rawEvt any) (bridgev2.Remot
 		wrapped = wrapMemberChange(&meta, meta.Sender, event.MembershipJoin, "")
 	case *slack.ChannelLeftEvent:
 		meta, metaErr = s.makeEventMeta(ctx, evt.Channel, nil, s.UserID, evt.Timestamp)
-		wrapped = wrapMemberChange(&meta, meta.Sender, event.MembershipLeave, event.MembershipJoin)
+		wrapped = s.wrapSelfLeave(&meta)
 	case *slack.GroupJoinedEvent:
 		meta, metaErr = s.makeEventMeta(ctx, evt.Channel.ID, &evt.Channel, s.UserID, "")
 		meta.CreatePortal = true
 		wrapped = wrapMemberChange(&meta, meta.Sender, event.MembershipJoin, "")
 	case *slack.GroupLeftEvent:
 		meta, metaErr = s.makeEventMeta(ctx, evt.Channel, nil, s.UserID, evt.Timestamp)
-		wrapped = wrapMemberChange(&meta, meta.Sender, event.MembershipLeave, event.MembershipJoin)
+		wrapped = s.wrapSelfLeave(&meta)
 	case *slack.MemberJoinedChannelEvent:
 		meta, metaErr = s.makeEventMeta(ctx, evt.Channel, nil, evt.User, evt.EventTimestamp)
 		wrapped = wrapMemberChange(&meta, meta.Sender, event.MembershipJoin, "")
 	case *slack.MemberLeftChannelEvent:
 		meta, metaErr = s.makeEventMeta(ctx, evt.Channel, nil, evt.User, evt.EventTimestamp)
-		wrapped = wrapMemberChange(&meta, meta.Sender, event.MembershipLeave, event.MembershipJoin)
+		if evt.User == s.UserID {
+			// Being removed by someone else only sends member_left_channel, not channel_left
+			wrapped = s.wrapSelfLeave(&meta)
+		} else {
+			wrapped = wrapMemberChange(&meta, meta.Sender, event.MembershipLeave, event.MembershipJoin)
+		}
 
 	case *slack.ChannelUpdateEvent:
 		meta, metaErr = s.makeEventMeta(ctx, evt.Channel, nil, "", evt.Timestamp)
 		meta.Type = bridgev2.RemoteEventChatResync
 		//meta.CreatePortal = true
-		wrapped = &meta
+		// Description edits from the channel details don't always come with a topic/purpose message,
+		// so always refetch the info and let the resync diff the topic and purpose.
+		s.invalidateChatInfoCache(evt.Channel)
+		wrapped = &SlackChatResync{
+			SlackEventMeta: &meta,
+			Client:         s,
+			ShouldSyncInfo: true,
+		}
 	}
 	return wrapped, metaErr
 }
@@ -282,6 +362,47 @@ func (s *SlackClient) getReactionInfo(ctx context.Context, reaction string) (emo
 	return
 }
 
+const reactionEchoTimeout = 1 * time.Minute
+
+func reactionEchoKey(channelID, messageTS, reaction string, add bool) string {
+	return fmt.Sprintf("%s/%s/%s/%t", channelID, messageTS, reaction, add)
+}
+
+// expectReactionEcho marks a reaction change sent from Matrix, so that the echo from Slack can be ignored.
+// Without this, echoes of a quick remove and re-add arrive after the state has changed again and undo it on Matrix.
+func (s *SlackClient) expectReactionEcho(channelID, messageTS, reaction string, add bool) {
+	s.reactionEchoesLock.Lock()
+	defer s.reactionEchoesLock.Unlock()
+	for key, ts := range s.reactionEchoes {
+		if time.Since(ts) > reactionEchoTimeout {
+			delete(s.reactionEchoes, key)
+		}
+	}
+	s.reactionEchoes[reactionEchoKey(channelID, messageTS, reaction, add)] = time.Now()
+}
+
+// cancelReactionEcho removes an expected echo if the reaction change didn't go through on Slack.
+func (s *SlackClient) cancelReactionEcho(channelID, messageTS, reaction string, add bool) {
+	s.reactionEchoesLock.Lock()
+	delete(s.reactionEchoes, reactionEchoKey(channelID, messageTS, reaction, add))
+	s.reactionEchoesLock.Unlock()
+}
+
+// isOwnReactionEcho checks whether a reaction event is the echo of a change that was sent from Matrix.
+func (s *SlackClient) isOwnReactionEcho(userID, reaction string, add bool, target slack.ReactionItem) bool {
+	if userID != s.UserID || target.Timestamp == "" {
+		return false
+	}
+	key := reactionEchoKey(target.Channel, target.Timestamp, reaction, add)
+	s.reactionEchoesLock.Lock()
+	defer s.reactionEchoesLock.Unlock()
+	ts, ok := s.reactionEchoes[key]
+	if ok {
+		delete(s.reactionEchoes, key)
+	}
+	return ok && time.Since(ts) <= reactionEchoTimeout
+}
+
 func (s *SlackClient) wrapReaction(ctx context.Context, meta *SlackEventMeta, reaction string, add bool, target slack.ReactionItem) (*SlackReaction, error) {
 	if add {
 		meta.Type = bridgev2.RemoteEventReaction
@@ -291,6 +412,7 @@ func (s *SlackClient) wrapReaction(ctx context.Context, meta *SlackEventMeta, re
 	emoji, extraContent := s.getReactionInfo(ctx, reaction)
 	return &SlackReaction{
 		SlackEventMeta: meta,
+		Client:         s,
 		Emoji:          emoji,
 		EmojiID:        networkid.EmojiID(reaction),
 		Meta:           extraContent,
@@ -308,6 +430,14 @@ func wrapReadReceipt(meta *SlackEventMeta) *SlackReadReceipt {
 	return &SlackReadReceipt{SlackEventMeta: meta}
 }
 
+func (s *SlackClient) wrapSelfLeave(meta *SlackEventMeta) bridgev2.RemoteEvent {
+	if s.Main.Config.DeletePortalOnLeave {
+		meta.Type = bridgev2.RemoteEventChatDelete
+		return &SlackChatDelete{SlackEventMeta: meta}
+	}
+	return wrapMemberChange(meta, meta.Sender, event.MembershipLeave, event.MembershipJoin)
+}
+
 func wrapMemberChange(meta *SlackEventMeta, sender bridgev2.EventSender, newMembership, prevMembership event.Membership) *SlackChatInfoChange {
 	meta.Type = bridgev2.RemoteEventChatInfoChange
 	meta.LogContext = func(c zerolog.Context) zerolog.Context {
@@ -423,6 +553,16 @@ func (s *SlackChatInfoChange) GetChatInfoChange(ctx context.Context) (*bridgev2.
 	return s.Change, nil
 }
 
+type SlackChatDelete struct {
+	*SlackEventMeta
+}
+
+var _ bridgev2.RemoteChatDelete = (*SlackChatDelete)(nil)
+
+func (s *SlackChatDelete) DeleteOnlyForMe() bool {
+	return true
+}
+
 type SlackReadReceipt struct {
 	*SlackEventMeta
 }
@@ -453,6 +593,7 @@ func (s *SlackTyping) GetTimeout() time.Duration {
 
 type SlackReaction struct {
 	*SlackEventMeta
+	Client   *SlackClient
 	TargetID networkid.MessageID
 	EmojiID  networkid.EmojiID
 	Emoji    string
@@ -475,16 +616,158 @@ func (s *SlackReaction) GetReactionExtraContent() map[string]any {
 	return s.Meta
 }
 
+// PreHandle makes sure the reactor's ghost is in the room before the reaction is sent.
+// Users who haven't sent any messages may not have been joined yet, which would make the reaction fail.
+func (s *SlackReaction) PreHandle(ctx context.Context, portal *bridgev2.Portal) {
+	if s.Type != bridgev2.RemoteEventReaction || portal.MXID == "" || s.Sender.IsFromMe {
+		return
+	}
+	ghost, err := s.Client.Main.br.GetGhostByID(ctx, s.Sender.Sender)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to get reactor ghost")
+		return
+	} else if ghost == nil {
+		return
+	}
+	ghost.UpdateInfoIfNecessary(ctx, s.Client.UserLogin, bridgev2.RemoteEventReaction)
+	err = ghost.Intent.EnsureJoined(ctx, portal.MXID)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to ensure reactor ghost is joined to portal")
+	}
+}
+
+// PostHandle sends a notice when the reacted message reaches the configured hot message threshold.
+func (s *SlackReaction) PostHandle(ctx context.Context, portal *bridgev2.Portal) {
+	threshold := s.Client.Main.Config.HotMessageThreshold
+	if s.Type != bridgev2.RemoteEventReaction || threshold <= 0 || portal.MXID == "" {
+		return
+	}
+	log := zerolog.Ctx(ctx)
+	db := s.Client.Main.br.DB
+	reactions, err := db.Reaction.GetAllToMessage(ctx, portal.Receiver, s.TargetID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get reactions to count for hot message threshold")
+		return
+	} else if len(reactions) < threshold {
+		return
+	}
+	target, err := db.Message.GetFirstPartByID(ctx, portal.Receiver, s.TargetID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get target message for hot message notice")
+		return
+	} else if target == nil || target.Metadata.(*slackid.MessageMetadata).HotNoticeSent {
+		return
+	}
+	target.Metadata.(*slackid.MessageMetadata).HotNoticeSent = true
+	err = db.Message.Update(ctx, target)
+	if err != nil {
+		log.Err(err).Msg("Failed to mark message as hot")
+		return
+	}
+	content := &event.MessageEventContent{
+		MsgType: event.MsgNotice,
+		Body:    fmt.Sprintf("\U0001F525 This message has %d reactions", len(reactions)),
+	}
+	content.SetReply(&event.Event{ID: target.MXID, RoomID: portal.MXID})
+	_, err = s.Client.Main.br.Bot.SendMessage(ctx, portal.MXID, event.EventMessage, &event.Content{
+		Parsed: content,
+		Raw: map[string]any{
+			"fi.mau.slack.hot_message": map[string]any{
+				"event_id":       target.MXID,
+				"reaction_count": len(reactions),
+			},
+		},
+	}, nil)
+	if err != nil {
+		log.Err(err).Msg("Failed to send hot message notice")
+	}
+}
+
 var (
 	_ bridgev2.RemoteReaction                 = (*SlackReaction)(nil)
 	_ bridgev2.RemoteReactionRemove           = (*SlackReaction)(nil)
 	_ bridgev2.RemoteReactionWithExtraContent = (*SlackReaction)(nil)
+	_ bridgev2.RemotePreHandler               = (*SlackReaction)(nil)
+	_ bridgev2.RemotePostHandler              = (*SlackReaction)(nil)
 )
 
 type SlackMessage struct {
 	*SlackEventMeta
-	Data   *slack.MessageEvent
-	Client *SlackClient
+	Data       *slack.MessageEvent
+	Client     *SlackClient
+	ReceivedAt time.Time
+	// The parts of the message, the new message and the previous message that slackgo doesn't parse.
+	// Only available for events received via RTM.
+	Extras, SubExtras, PrevExtras *msgconv.EventExtras
+	// Set for the copy of a channel rename message that is bridged as a notice after the name resync.
+	IsRenameNotice bool
+}
+
+// makeRenameNotice returns a copy of channel rename messages that will be bridged as a notice
+// attributed to the user who renamed the channel, as the resync itself only changes the room name.
+func makeRenameNotice(evt bridgev2.RemoteEvent) bridgev2.RemoteEvent {
+	msg, ok := evt.(*SlackMessage)
+	if !ok || (msg.Data.SubType != slack.MsgSubTypeChannelName && msg.Data.SubType != slack.MsgSubTypeGroupName) {
+		return nil
+	}
+	notice := *msg
+	notice.IsRenameNotice = true
+	return &notice
+}
+
+type rawMessageExtras struct {
+	Room   *msgconv.HuddleRoom `json:"room"`
+	Blocks []struct {
+		Type   string `json:"type"`
+		CallID string `json:"call_id"`
+		Call   struct {
+			V1 *msgconv.CallInfo `json:"v1"`
+		} `json:"call"`
+	} `json:"blocks"`
+}
+
+func (raw *rawMessageExtras) toExtras() *msgconv.EventExtras {
+	if raw == nil {
+		return nil
+	}
+	extras := &msgconv.EventExtras{HuddleRoom: raw.Room}
+	for _, block := range raw.Blocks {
+		if block.Type == "call" && block.Call.V1 != nil {
+			if extras.Calls == nil {
+				extras.Calls = make(map[string]*msgconv.CallInfo)
+			}
+			extras.Calls[block.CallID] = block.Call.V1
+		}
+	}
+	if extras.HuddleRoom == nil && extras.Calls == nil {
+		return nil
+	}
+	return extras
+}
+
+// rtmMessageEvent is a message event that also parses the huddle room and call objects, which slackgo drops.
+type rtmMessageEvent struct {
+	slack.MessageEvent
+	Extras, SubExtras, PrevExtras *msgconv.EventExtras
+}
+
+func (evt *rtmMessageEvent) UnmarshalJSON(data []byte) error {
+	err := json.Unmarshal(data, &evt.MessageEvent)
+	if err != nil {
+		return err
+	}
+	var raw struct {
+		rawMessageExtras
+		Message         *rawMessageExtras `json:"message"`
+		PreviousMessage *rawMessageExtras `json:"previous_message"`
+	}
+	// The extra objects are only used for rendering, so the event is still handled if they can't be parsed
+	if json.Unmarshal(data, &raw) == nil {
+		evt.Extras = raw.rawMessageExtras.toExtras()
+		evt.SubExtras = raw.Message.toExtras()
+		evt.PrevExtras = raw.PreviousMessage.toExtras()
+	}
+	return nil
 }
 
 func (s *SlackMessage) GetTransactionID() networkid.TransactionID {
@@ -499,6 +782,7 @@ var (
 	_ bridgev2.RemoteEdit                     = (*SlackMessage)(nil)
 	_ bridgev2.RemoteMessageRemove            = (*SlackMessage)(nil)
 	_ bridgev2.RemoteChatResync               = (*SlackMessage)(nil)
+	_ bridgev2.RemoteChatResyncWithInfo       = (*SlackMessage)(nil)
 	_ bridgev2.RemoteMessageWithTransactionID = (*SlackMessage)(nil)
 )
 
@@ -536,6 +820,9 @@ var (
 )
 
 func (s *SlackMessage) GetType() bridgev2.RemoteEventType {
+	if s.IsRenameNotice {
+		return bridgev2.RemoteEventMessage
+	}
 	switch s.Data.SubType {
 	case slack.MsgSubTypeMessageChanged:
 		return bridgev2.RemoteEventEdit
@@ -548,7 +835,7 @@ func (s *SlackMessage) GetType() bridgev2.RemoteEventType {
 	case slack.MsgSubTypeMessageReplied, slack.MsgSubTypeGroupJoin, slack.MsgSubTypeGroupLeave,
 		slack.MsgSubTypeChannelJoin, slack.MsgSubTypeChannelLeave:
 		return bridgev2.RemoteEventUnknown
-	case "", slack.MsgSubTypeMeMessage, slack.MsgSubTypeBotMessage, slack.MsgSubTypeThreadBroadcast, "huddle_thread":
+	case "", slack.MsgSubTypeMeMessage, slack.MsgSubTypeBotMessage, slack.MsgSubTypeThreadBroadcast, "huddle_thread", "sh_room_created":
 		// Known types
 		return bridgev2.RemoteEventMessage
 	default:
@@ -560,12 +847,236 @@ func (s *SlackMessage) GetType() bridgev2.RemoteEventType {
 	}
 }
 
+func (s *SlackMessage) GetChatInfo(ctx context.Context, portal *bridgev2.Portal) (*bridgev2.ChatInfo, error) {
+	switch s.Data.SubType {
+	case slack.MsgSubTypeChannelTopic, slack.MsgSubTypeGroupTopic:
+		return &bridgev2.ChatInfo{Topic: ptr.Ptr(s.Data.Topic)}, nil
+	case slack.MsgSubTypeChannelPurpose, slack.MsgSubTypeGroupPurpose:
+		return &bridgev2.ChatInfo{ExtraUpdates: s.Client.makePurposeUpdater(s.Data.Purpose)}, nil
+	default:
+		// The name has to go through the channel name template, so refetch the whole info
+		_, channelID := slackid.ParsePortalID(portal.ID)
+		s.Client.invalidateChatInfoCache(channelID)
+		return s.Client.GetChatInfo(ctx, portal)
+	}
+}
+
 func (s *SlackMessage) ConvertMessage(ctx context.Context, portal *bridgev2.Portal, intent bridgev2.MatrixAPI) (*bridgev2.ConvertedMessage, error) {
-	return s.Client.Main.MsgConv.ToMatrix(ctx, portal, intent, s.Client.UserLogin, &s.Data.Msg), nil
+	if s.Client.Main.shouldSuppressSystemMessage(portal, s.Data.SubType) || s.Client.Main.shouldSuppressPrompt(&s.Data.Msg) {
+		return nil, bridgev2.ErrIgnoringRemoteEvent
+	} else if s.isClientMsgIDDuplicate(ctx, portal) {
+		return nil, bridgev2.ErrIgnoringRemoteEvent
+	}
+	ctx = msgconv.WithEventExtras(ctx, s.Extras)
+	converted := s.Client.Main.MsgConv.ToMatrix(ctx, portal, intent, s.Client.UserLogin, &s.Data.Msg)
+	if msgconv.HasPendingFiles(&s.Data.Msg) {
+		go s.Client.bridgePendingFiles(portal.PortalKey, s.Sender, &s.Data.Msg)
+	}
+	return converted, nil
+}
+
+// bridgePendingFiles waits for Slack to finish processing the files of a message that were bridged as placeholders,
+// then queues an edit to replace the placeholders. This is done in the background to avoid blocking the portal.
+func (s *SlackClient) bridgePendingFiles(portalKey networkid.PortalKey, sender bridgev2.EventSender, msg *slack.Msg) {
+	log := s.UserLogin.Log.With().
+		Str("action", "bridge pending files").
+		Str("channel_id", msg.Channel).
+		Str("message_ts", msg.Timestamp).
+		Logger()
+	ctx := log.WithContext(context.Background())
+	updated := *msg
+	updated.Files = slices.Clone(msg.Files)
+	anyReady := false
+	for i, file := range updated.Files {
+		if !msgconv.IsPendingFile(&file) {
+			continue
+		}
+		readyFile, err := msgconv.WaitForPendingFile(ctx, s.Client, file.ID)
+		if err != nil {
+			log.Err(err).Str("file_id", file.ID).Msg("File didn't finish processing on Slack")
+			continue
+		}
+		updated.Files[i] = *readyFile
+		anyReady = true
+	}
+	if !anyReady {
+		return
+	}
+	s.Main.br.QueueRemoteEvent(s.UserLogin, &SlackMessage{
+		SlackEventMeta: &SlackEventMeta{
+			Type:      bridgev2.RemoteEventEdit,
+			PortalKey: portalKey,
+			Sender:    sender,
+			LogContext: func(c zerolog.Context) zerolog.Context {
+				return c.Str("message_ts", msg.Timestamp).Str("subtype", "pending_files_ready")
+			},
+		},
+		Data: &slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:      slack.TYPE_MESSAGE,
+				SubType:   slack.MsgSubTypeMessageChanged,
+				Channel:   msg.Channel,
+				Timestamp: msg.Timestamp,
+			},
+			SubMessage:      &updated,
+			PreviousMessage: msg,
+		},
+		Client:     s,
+		ReceivedAt: time.Now(),
+	})
+}
+
+const (
+	// clientMsgIDDedupWindow is how far back messages are checked for a matching client_msg_id.
+	clientMsgIDDedupWindow = 1 * time.Hour
+	// clientMsgIDDedupAfterConnect is how long after (re)connecting incoming messages are checked for duplicates.
+	// Slack only redelivers messages around reconnects, so later messages don't need the extra query.
+	clientMsgIDDedupAfterConnect = 5 * time.Minute
+)
+
+// isClientMsgIDDuplicate checks whether a message with the same client_msg_id but a different ID
+// has already been bridged, which can happen when messages are redelivered after reconnecting.
+func (s *SlackMessage) isClientMsgIDDuplicate(ctx context.Context, portal *bridgev2.Portal) bool {
+	if !s.Client.Main.Config.DedupClientMsgID || s.Data.ClientMsgID == "" || s.IsRenameNotice {
+		return false
+	} else if s.ReceivedAt.Sub(time.Unix(0, s.Client.lastConnect.Load())) > clientMsgIDDedupAfterConnect {
+		return false
+	}
+	minTS := slackid.ParseSlackTimestamp(s.Data.Timestamp).Add(-clientMsgIDDedupWindow)
+	recent, err := s.Client.Main.br.DB.Message.GetMessagesBetweenTimeQuery(ctx, portal.PortalKey, minTS, time.Now())
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to get recent messages to check for same client_msg_id")
+		return false
+	}
+	for _, msg := range recent {
+		if msg.ID != s.ID && msg.Metadata.(*slackid.MessageMetadata).ClientMsgID == s.Data.ClientMsgID {
+			zerolog.Ctx(ctx).Debug().
+				Str("client_msg_id", s.Data.ClientMsgID).
+				Str("existing_message_id", string(msg.ID)).
+				Msg("Ignoring duplicate message with same client_msg_id")
+			return true
+		}
+	}
+	return false
+}
+
+func isSystemMessageSubtype(subtype string) bool {
+	switch subtype {
+	case slack.MsgSubTypeChannelJoin, slack.MsgSubTypeChannelLeave, slack.MsgSubTypeGroupJoin, slack.MsgSubTypeGroupLeave,
+		slack.MsgSubTypeChannelTopic, slack.MsgSubTypeChannelPurpose, slack.MsgSubTypeChannelName,
+		slack.MsgSubTypeGroupTopic, slack.MsgSubTypeGroupPurpose, slack.MsgSubTypeGroupName,
+		slack.MsgSubTypeChannelArchive, slack.MsgSubTypeChannelUnarchive, slack.MsgSubTypeGroupArchive, slack.MsgSubTypeGroupUnarchive,
+		slack.MsgSubTypePinnedItem, slack.MsgSubTypeUnpinnedItem, slack.MsgSubTypeChannelPostingPermissions,
+		"bot_add", "bot_remove", "channel_convert_to_private", "channel_convert_to_public":
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *SlackConnector) shouldSuppressSystemMessage(portal *bridgev2.Portal, subtype string) bool {
+	if !isSystemMessageSubtype(subtype) {
+		return false
+	} else if override := portal.Metadata.(*slackid.PortalMetadata).SuppressSystemMessages; override != nil {
+		return *override
+	}
+	return s.Config.SuppressSystemMessages
+}
+
+// isSlackPrompt checks whether the message is a UI nudge generated by Slack itself
+// (e.g. suggestions to reply in a thread or to greet new members) rather than actual content.
+func isSlackPrompt(msg *slack.Msg) bool {
+	if msg.IsEphemeral && msg.User == "USLACKBOT" && !strings.Contains(strings.ToLower(msg.Text), "remind") {
+		// Nudges like "reply in thread?" are ephemeral messages from Slackbot. Ephemeral messages from other
+		// bots are usually replies to slash commands, and reminder confirmations come from Slackbot too,
+		// so those aren't considered prompts.
+		return true
+	}
+	switch msg.SubType {
+	case "joiner_notification", "joiner_notification_for_inviter":
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *SlackConnector) shouldSuppressPrompt(msg *slack.Msg) bool {
+	return s.Config.SuppressSlackPrompts && isSlackPrompt(msg)
+}
+
+func jsonEqual(a, b any) bool {
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aJSON, bJSON)
+}
+
+// isThreadMetadataOnlyChange checks whether a message_changed event only updated the thread metadata
+// of a thread root (reply count, latest reply, etc.) rather than the content of the message.
+func isThreadMetadataOnlyChange(prev, cur *slack.Msg) bool {
+	if prev == nil || cur == nil {
+		return false
+	} else if cur.Edited != nil && (prev.Edited == nil || prev.Edited.Timestamp != cur.Edited.Timestamp) {
+		return false
+	}
+	return prev.Text == cur.Text &&
+		getThreadTS(prev) == getThreadTS(cur) &&
+		jsonEqual(prev.Blocks, cur.Blocks) &&
+		jsonEqual(prev.Attachments, cur.Attachments) &&
+		jsonEqual(prev.Files, cur.Files)
 }
 
 func (s *SlackMessage) ConvertEdit(ctx context.Context, portal *bridgev2.Portal, intent bridgev2.MatrixAPI, existing []*database.Message) (*bridgev2.ConvertedEdit, error) {
-	return s.Client.Main.MsgConv.EditToMatrix(ctx, portal, intent, s.Client.UserLogin, s.Data.SubMessage, s.Data.PreviousMessage, existing), nil
+	// Edits must only replace the content: thread roots get a message_changed event for every new reply,
+	// and bridging those as edits would bump the root instead of keeping the thread stable.
+	// Calls and huddles are an exception, as participant changes only change the call or room object.
+	if isThreadMetadataOnlyChange(s.Data.PreviousMessage, s.Data.SubMessage) && jsonEqual(s.PrevExtras, s.SubExtras) {
+		return nil, bridgev2.ErrIgnoringRemoteEvent
+	}
+	ctx = msgconv.WithEventExtras(ctx, s.SubExtras)
+	converted := s.Client.Main.MsgConv.EditToMatrix(ctx, portal, intent, s.Client.UserLogin, s.Data.SubMessage, s.Data.PreviousMessage, existing)
+	if s.Data.PreviousMessage != nil && getThreadTS(s.Data.PreviousMessage) != getThreadTS(s.Data.SubMessage) {
+		s.handleThreadChange(ctx, portal, existing, converted)
+	}
+	return converted, nil
+}
+
+// getThreadTS returns the timestamp of the thread root the message is in, or an empty string for messages
+// that aren't in a thread (including thread roots themselves).
+func getThreadTS(msg *slack.Msg) string {
+	if msg.ThreadTimestamp == msg.Timestamp {
+		return ""
+	}
+	return msg.ThreadTimestamp
+}
+
+// handleThreadChange updates the stored thread root of an edited message that was moved to another thread.
+// Matrix edits can't change relations, so the change is also noted in the edited content.
+func (s *SlackMessage) handleThreadChange(ctx context.Context, portal *bridgev2.Portal, existing []*database.Message, converted *bridgev2.ConvertedEdit) {
+	newThreadTS := getThreadTS(s.Data.SubMessage)
+	var newThreadRoot networkid.MessageID
+	note := "(moved out of the thread on Slack)"
+	if newThreadTS != "" {
+		teamID, channelID := slackid.ParsePortalID(portal.ID)
+		newThreadRoot = slackid.MakeMessageID(teamID, channelID, newThreadTS)
+		note = "(moved to another thread on Slack)"
+	}
+	zerolog.Ctx(ctx).Debug().
+		Str("old_thread_ts", getThreadTS(s.Data.PreviousMessage)).
+		Str("new_thread_ts", newThreadTS).
+		Msg("Edit changed thread of message")
+	for _, part := range existing {
+		part.ThreadRoot = newThreadRoot
+		err := s.Client.Main.br.DB.Message.Update(ctx, part)
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Str("part_id", string(part.PartID)).Msg("Failed to update thread root of message part")
+		}
+	}
+	for _, part := range converted.ModifiedParts {
+		part.Content.Body += "\n\n" + note
+		if part.Content.Format == event.FormatHTML {
+			part.Content.FormattedBody += "<br><br><i>" + note + "</i>"
+		}
+	}
 }
 
 func (s *SlackMessage) GetTimestamp() time.Time {
@@ -573,6 +1084,9 @@ func (s *SlackMessage) GetTimestamp() time.Time {
 	case slack.MsgSubTypeMessageChanged:
 		return slackid.ParseSlackTimestamp(s.Data.EventTimestamp)
 	default:
+		if s.Client.Main.Config.TimestampSource == TimestampSourceReceive && !s.ReceivedAt.IsZero() {
+			return s.ReceivedAt
+		}
 		return slackid.ParseSlackTimestamp(s.Data.Timestamp)
 	}
 }
@@ -584,7 +1098,13 @@ func (s *SlackMessage) GetID() networkid.MessageID {
 func (s *SlackMessage) GetTargetMessage() networkid.MessageID {
 	switch s.Data.SubType {
 	case slack.MsgSubTypeMessageDeleted:
-		return slackid.MakeMessageID(s.Client.TeamID, s.Data.Channel, s.Data.DeletedTimestamp)
+		deletedTS := s.Data.DeletedTimestamp
+		if deletedTS == "" && s.Data.PreviousMessage != nil {
+			deletedTS = s.Data.PreviousMessage.Timestamp
+		}
+		// The database always points at the original event of each part (edits only add m.replace events),
+		// so redacting the parts of the target message also hides all edits of it.
+		return slackid.MakeMessageID(s.Client.TeamID, s.Data.Channel, deletedTS)
 	case slack.MsgSubTypeMessageChanged:
 		// Socket mode events don't have the target timestamp at the top level
 		// TODO always just use the submessage timestamp?
@@ -0,0 +1,158 @@
+// mautrix-slack - A Matrix-Slack puppeting bridge.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+	"maunium.net/go/mautrix/bridgev2"
+
+	"go.mau.fi/mautrix-slack/pkg/slackid"
+)
+
+// callSlackAPI calls a Slack web API method that isn't wrapped by slackgo.
+func (s *SlackClient) callSlackAPI(ctx context.Context, method string, values url.Values, into any) error {
+	meta := s.UserLogin.Metadata.(*slackid.UserLoginMetadata)
+	values.Set("token", meta.Token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, slack.APIURL+method, strings.NewReader(values.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to prepare request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if meta.CookieToken != "" {
+		req.AddCookie(&http.Cookie{Name: "d", Value: meta.CookieToken})
+	}
+	resp, err := s.Main.MsgConv.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	var slackResp slack.SlackResponse
+	var data json.RawMessage
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	} else if err = json.Unmarshal(data, &slackResp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	} else if err = slackResp.Err(); err != nil {
+		return err
+	} else if into != nil {
+		return json.Unmarshal(data, into)
+	}
+	return nil
+}
+
+type SharedChannelInvite struct {
+	Direction string `json:"direction"`
+	Status    string `json:"status"`
+	Invite    struct {
+		ID           string `json:"id"`
+		DateCreated  int64  `json:"date_created"`
+		DateInvalid  int64  `json:"date_invalid"`
+		InvitingTeam struct {
+			ID     string `json:"id"`
+			Name   string `json:"name"`
+			Domain string `json:"domain"`
+		} `json:"inviting_team"`
+		InvitingUser struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"inviting_user"`
+	} `json:"invite"`
+	Channel struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		IsPrivate bool   `json:"is_private"`
+	} `json:"channel"`
+}
+
+func (s *SlackClient) ListSharedChannelInvites(ctx context.Context) ([]SharedChannelInvite, error) {
+	var resp struct {
+		Invites []SharedChannelInvite `json:"invites"`
+	}
+	err := s.callSlackAPI(ctx, "conversations.listConnectInvites", url.Values{
+		"team_id": {s.TeamID},
+		"count":   {"100"},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	pending := resp.Invites[:0]
+	for _, invite := range resp.Invites {
+		switch invite.Status {
+		case "accepted", "declined", "revoked":
+			// Already handled, can't be accepted anymore
+		default:
+			pending = append(pending, invite)
+		}
+	}
+	return pending, nil
+}
+
+func (s *SlackClient) AcceptSharedInvite(ctx context.Context, invite *SharedChannelInvite) (string, error) {
+	var resp struct {
+		ChannelID string `json:"channel_id"`
+	}
+	err := s.callSlackAPI(ctx, "conversations.acceptSharedInvite", url.Values{
+		"invite_id":    {invite.Invite.ID},
+		"channel_name": {invite.Channel.Name},
+		"is_private":   {strconv.FormatBool(invite.Channel.IsPrivate)},
+		"team_id":      {s.TeamID},
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.ChannelID, nil
+}
+
+// queueChannelCreate fetches the info of a channel and queues a resync that will create the portal room.
+func (s *SlackClient) queueChannelCreate(ctx context.Context, channelID string) error {
+	ch, err := s.Client.GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{
+		ChannelID:         channelID,
+		IncludeLocale:     true,
+		IncludeNumMembers: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch channel info: %w", err)
+	}
+	ch.IsMember = true
+	portalKey := s.makePortalKey(ch)
+	s.Main.br.QueueRemoteEvent(s.UserLogin, &SlackChatResync{
+		SlackEventMeta: &SlackEventMeta{
+			Type:         bridgev2.RemoteEventChatResync,
+			PortalKey:    portalKey,
+			CreatePortal: true,
+			LogContext: func(c zerolog.Context) zerolog.Context {
+				return c.Object("portal_key", portalKey)
+			},
+		},
+		Client:         s,
+		PreFetchedInfo: ch,
+	})
+	return nil
+}
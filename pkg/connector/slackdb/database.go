@@ -17,11 +17,14 @@
 package slackdb
 
 import (
+	"context"
 	"embed"
+	"strings"
 	"sync"
 
 	"github.com/rs/zerolog"
 	"go.mau.fi/util/dbutil"
+	"maunium.net/go/mautrix/bridgev2/networkid"
 )
 
 type SlackDB struct {
@@ -48,3 +51,24 @@ func New(db *dbutil.Database, log zerolog.Logger) *SlackDB {
 		},
 	}
 }
+
+// getGhostIDsInTeamQuery reads the ghost table owned by bridgev2, which doesn't have a query for listing ghosts.
+// It relies on the bridge_id and id columns and on ghost IDs being prefixed with the lowercase team ID
+// (see slackid.MakeUserID), so it must be kept in sync with both.
+const getGhostIDsInTeamQuery = `SELECT id FROM ghost WHERE bridge_id=$1 AND id LIKE $2`
+
+// GetGhostIDsInTeam returns the IDs of all ghosts in the given team from the bridgev2 ghost table.
+func (db *SlackDB) GetGhostIDsInTeam(ctx context.Context, bridgeID networkid.BridgeID, teamID string) ([]networkid.UserID, error) {
+	rows, err := db.Query(ctx, getGhostIDsInTeamQuery, bridgeID, strings.ToLower(teamID)+"-%")
+	return dbutil.NewRowIterWithError(rows, dbutil.ScanSingleColumn[networkid.UserID], err).AsList()
+}
+
+// getGhostIDsWithStatusQuery reads the bridgev2 ghost table like getGhostIDsInTeamQuery,
+// and additionally relies on the status_message key of slackid.GhostMetadata.
+const getGhostIDsWithStatusQuery = `SELECT id FROM ghost WHERE bridge_id=$1 AND id LIKE $2 AND metadata->>'status_message' IS NOT NULL`
+
+// GetGhostIDsWithStatus returns the IDs of all ghosts in the given team that have a status message set.
+func (db *SlackDB) GetGhostIDsWithStatus(ctx context.Context, bridgeID networkid.BridgeID, teamID string) ([]networkid.UserID, error) {
+	rows, err := db.Query(ctx, getGhostIDsWithStatusQuery, bridgeID, strings.ToLower(teamID)+"-%")
+	return dbutil.NewRowIterWithError(rows, dbutil.ScanSingleColumn[networkid.UserID], err).AsList()
+}
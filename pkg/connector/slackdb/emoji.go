@@ -25,6 +25,7 @@ import (
 	"sync"
 
 	"go.mau.fi/util/dbutil"
+	"maunium.net/go/mautrix/bridgev2/networkid"
 	"maunium.net/go/mautrix/id"
 )
 
@@ -50,6 +51,9 @@ const (
 	getEmojiByMXCQuery = `
 		SELECT team_id, emoji_id, value, alias, image_mxc FROM emoji WHERE image_mxc=$1 ORDER BY alias NULLS FIRST
 	`
+	getAllEmojiInTeamQuery = `
+		SELECT team_id, emoji_id, value, alias, image_mxc FROM emoji WHERE team_id=$1
+	`
 	getEmojiCountInTeamQuery = `
 		SELECT COUNT(*) FROM emoji WHERE team_id=$1
 	`
@@ -60,6 +64,8 @@ const (
 			SET value = excluded.value, alias = excluded.alias, image_mxc = excluded.image_mxc
 	`
 	renameEmojiQuery         = `UPDATE emoji SET emoji_id=$3 WHERE team_id=$1 AND emoji_id=$2`
+	renameEmojiAliasQuery    = `UPDATE emoji SET alias=$3, value='alias:' || $3 WHERE team_id=$1 AND alias=$2`
+	renameEmojiReactionQuery = `UPDATE reaction SET emoji_id=$4 WHERE bridge_id=$1 AND message_id LIKE $2 AND emoji_id=$3`
 	saveEmojiMXCQuery        = `UPDATE emoji SET image_mxc=$3 WHERE team_id=$1 AND (emoji_id=$2 OR alias=$2)`
 	deleteEmojiQueryPostgres = `DELETE FROM emoji WHERE team_id=$1 AND emoji_id=ANY($2)`
 	deleteEmojiQuerySQLite   = `DELETE FROM emoji WHERE team_id=? AND emoji_id IN (?)`
@@ -93,6 +99,10 @@ func (eq *EmojiQuery) GetBySlackID(ctx context.Context, teamID, emojiID string)
 	return eq.QueryOne(ctx, getEmojiBySlackIDQuery, teamID, emojiID)
 }
 
+func (eq *EmojiQuery) GetAllInTeam(ctx context.Context, teamID string) ([]*Emoji, error) {
+	return eq.QueryMany(ctx, getAllEmojiInTeamQuery, teamID)
+}
+
 func (eq *EmojiQuery) GetByMXC(ctx context.Context, mxc string) (*Emoji, error) {
 	return eq.QueryOne(ctx, getEmojiByMXCQuery, &mxc)
 }
@@ -134,7 +144,23 @@ func (eq *EmojiQuery) Put(ctx context.Context, emoji *Emoji) error {
 }
 
 func (eq *EmojiQuery) Rename(ctx context.Context, emoji *Emoji, newID string) error {
-	return eq.Exec(ctx, renameEmojiQuery, emoji.TeamID, emoji.EmojiID, newID)
+	return eq.GetDB().DoTxn(ctx, nil, func(ctx context.Context) error {
+		err := eq.Exec(ctx, renameEmojiQuery, emoji.TeamID, emoji.EmojiID, newID)
+		if err != nil {
+			return err
+		}
+		return eq.Exec(ctx, renameEmojiAliasQuery, emoji.TeamID, emoji.EmojiID, newID)
+	})
+}
+
+// RenameReactions updates the emoji ID of bridged reactions in the given team,
+// so that removing reactions still works after the emoji is renamed.
+//
+// This modifies the reaction table owned by bridgev2 directly, as bridgev2 has no query for updating
+// reactions by emoji. It relies on the bridge_id, message_id and emoji_id columns and on message IDs
+// being prefixed with the team ID (see slackid.MakeMessageID), so it must be kept in sync with both.
+func (eq *EmojiQuery) RenameReactions(ctx context.Context, bridgeID networkid.BridgeID, teamID, oldID, newID string) error {
+	return eq.Exec(ctx, renameEmojiReactionQuery, bridgeID, teamID+"-%", oldID, newID)
 }
 
 func (eq *EmojiQuery) SaveMXC(ctx context.Context, emoji *Emoji) error {
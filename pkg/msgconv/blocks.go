@@ -36,6 +36,7 @@ import (
 	"maunium.net/go/mautrix/format"
 	"maunium.net/go/mautrix/id"
 
+	"go.mau.fi/mautrix-slack/pkg/emoji"
 	"go.mau.fi/mautrix-slack/pkg/msgconv/mrkdwn"
 )
 
@@ -140,7 +141,11 @@ func (mc *MessageConverter) mrkdwnToMatrixHtml(ctx context.Context, inputMrkdwn
 
 func (mc *MessageConverter) renderSlackTextBlock(ctx context.Context, block slack.TextBlockObject, mentions *event.Mentions) string {
 	if block.Type == slack.PlainTextType {
-		return event.TextToHTML(block.Text)
+		text := block.Text
+		if block.Emoji {
+			text = emoji.ReplaceShortcodesWithUnicode(text)
+		}
+		return event.TextToHTML(text)
 	} else if block.Type == slack.MarkdownType {
 		return mc.mrkdwnToMatrixHtml(ctx, block.Text, mentions)
 	} else {
@@ -214,8 +219,12 @@ func (mc *MessageConverter) renderRichTextSectionElements(ctx context.Context, e
 			_, _ = fmt.Fprintf(&htmlText, `<a href="%s">%s</a>`, html.EscapeString(e.URL), event.TextToHTML(linkText))
 			closingTags(&htmlText, e.Style)
 		case *slack.RichTextSectionBroadcastElement:
-			mentions.Room = true
-			htmlText.WriteString("@room")
+			if roomPingSuppressed(ctx) {
+				_, _ = fmt.Fprintf(&htmlText, "@%s", e.Range)
+			} else {
+				mentions.Room = true
+				htmlText.WriteString("@room")
+			}
 		case *slack.RichTextSectionEmojiElement:
 			openingTags(&htmlText, e.Style)
 			if e.Unicode != "" {
@@ -251,7 +260,10 @@ func (mc *MessageConverter) renderRichTextSectionElements(ctx context.Context, e
 func (mc *MessageConverter) renderSlackBlock(ctx context.Context, block slack.Block, mentions *event.Mentions) (string, bool) {
 	switch b := block.(type) {
 	case *slack.HeaderBlock:
-		return fmt.Sprintf("<h1>%s</h1>", mc.renderSlackTextBlock(ctx, *b.Text, mentions)), false
+		headerText := *b.Text
+		// Slack always renders emoji shortcodes in headers, even if the emoji flag isn't set
+		headerText.Emoji = true
+		return fmt.Sprintf("<h1>%s</h1>", mc.renderSlackTextBlock(ctx, headerText, mentions)), false
 	case *slack.DividerBlock:
 		return "<hr>", false
 	case *slack.SectionBlock:
@@ -295,6 +307,11 @@ func (mc *MessageConverter) renderSlackBlock(ctx context.Context, block slack.Bl
 			}
 		}
 		return htmlText.String(), unsupported
+	case *slack.FileBlock:
+		// File blocks are bridged as separate file parts
+		return "", false
+	case *slack.CallBlock:
+		return mc.renderCallBlock(ctx, b), false
 	default:
 		zerolog.Ctx(ctx).Debug().
 			Type("block_type", b).
@@ -349,7 +366,8 @@ func (mc *MessageConverter) renderSlackRichTextLists(
 		list := lists[i]
 		if list.Indent > firstList.Indent {
 			var subLists []*slack.RichTextList
-			for ; i < len(lists) && lists[i].Indent > firstList.Indent; i++ {
+			// Lists of a different style at the same indent are separate lists, so stop at them
+			for ; i < len(lists) && lists[i].Indent > firstList.Indent && acceptListNest(lists[i], list.Indent, list.Style); i++ {
 				subLists = append(subLists, lists[i])
 			}
 			i--
@@ -384,22 +402,24 @@ func (mc *MessageConverter) renderSlackRichTextElements(
 	into *strings.Builder,
 ) {
 	for i := 0; i < len(elements); i++ {
-		bqDepth := getBlockquoteDepth(elements[i])
-		if bqDepth-existingBQDepth > 0 {
+		if getBlockquoteDepth(elements[i]) > existingBQDepth {
+			// Only open one level of quotes at a time: deeper elements will open more levels in the recursive call,
+			// while lists and quotes at this depth stay in the same blockquote.
 			into.WriteString("<blockquote>")
 			var subElements []slack.RichTextElement
-			for ; i < len(elements) && getBlockquoteDepth(elements[i]) >= bqDepth; i++ {
+			for ; i < len(elements) && getBlockquoteDepth(elements[i]) > existingBQDepth; i++ {
 				subElements = append(subElements, elements[i])
 			}
 			i--
-			mc.renderSlackRichTextElements(ctx, subElements, mentions, bqDepth, into)
+			mc.renderSlackRichTextElements(ctx, subElements, mentions, existingBQDepth+1, into)
 			into.WriteString("</blockquote>")
 			continue
 		}
 		firstList, ok := elements[i].(*slack.RichTextList)
 		if ok {
 			var subLists []*slack.RichTextList
-			for ; i < len(elements) && acceptListNest(elements[i], firstList.Indent, firstList.Style); i++ {
+			for ; i < len(elements) && getBlockquoteDepth(elements[i]) == existingBQDepth &&
+				acceptListNest(elements[i], firstList.Indent, firstList.Style); i++ {
 				subLists = append(subLists, elements[i].(*slack.RichTextList))
 			}
 			i--
@@ -422,7 +442,11 @@ func (mc *MessageConverter) renderSlackRichTextElement(ctx context.Context, numE
 		children := mc.renderRichTextSectionElements(ctx, e.Elements, mentions)
 		return fmt.Sprintf("<pre><code>%s</code></pre>", children)
 	case *slack.RichTextQuote:
-		return mc.renderRichTextSectionElements(ctx, e.Elements, mentions)
+		children := mc.renderRichTextSectionElements(ctx, e.Elements, mentions)
+		if numElements == 1 {
+			return children
+		}
+		return fmt.Sprintf("<p>%s</p>", children)
 	case *slack.RichTextList:
 		panic("renderSlackRichTextElement should not be called with RichTextList")
 	default:
@@ -431,22 +455,94 @@ func (mc *MessageConverter) renderSlackRichTextElement(ctx context.Context, numE
 	}
 }
 
+// interactiveBlockLabels returns the user-visible labels of an interactive block (buttons, inputs),
+// and whether the block is interactive at all.
+func interactiveBlockLabels(block slack.Block) ([]string, bool) {
+	switch b := block.(type) {
+	case *slack.ActionBlock:
+		var labels []string
+		if b.Elements != nil {
+			for _, elem := range b.Elements.ElementSet {
+				if button, ok := elem.(*slack.ButtonBlockElement); ok && button.Text != nil && button.Text.Text != "" {
+					labels = append(labels, button.Text.Text)
+				}
+			}
+		}
+		return labels, true
+	case *slack.InputBlock:
+		if b.Label != nil && b.Label.Text != "" {
+			return []string{b.Label.Text}, true
+		}
+		return nil, true
+	default:
+		return nil, false
+	}
+}
+
+// renderInteractiveSummary renders a note listing the given HTML labels of interactive elements (like buttons),
+// which can't be used from Matrix.
+func renderInteractiveSummary(labels []string) string {
+	if len(labels) == 0 {
+		return "<i>This message has interactive elements only available on Slack.</i>"
+	}
+	return fmt.Sprintf("<i>Interactive elements only available on Slack: %s</i>", strings.Join(labels, ", "))
+}
+
+// renderAttachmentActions renders the labels of legacy attachment actions. Link buttons are rendered as links,
+// as they don't need any interaction with Slack.
+func renderAttachmentActions(actions []slack.AttachmentAction) string {
+	labels := make([]string, 0, len(actions))
+	for _, action := range actions {
+		if action.Text == "" {
+			continue
+		} else if action.URL != "" {
+			labels = append(labels, fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(action.URL), html.EscapeString(action.Text)))
+		} else {
+			labels = append(labels, html.EscapeString(action.Text))
+		}
+	}
+	return renderInteractiveSummary(labels)
+}
+
 func (mc *MessageConverter) blocksToHTML(ctx context.Context, blocks slack.Blocks, alwaysWrap bool, mentions *event.Mentions) string {
 	var htmlText strings.Builder
 
-	if len(blocks.BlockSet) == 1 && !alwaysWrap {
+	// Interactive blocks can't be used from Matrix, so they're summarized at the end
+	// instead of being rendered inline, which keeps the content blocks intact.
+	contentBlocks := make([]slack.Block, 0, len(blocks.BlockSet))
+	var interactiveLabels []string
+	hasInteractive := false
+	for _, block := range blocks.BlockSet {
+		if labels, ok := interactiveBlockLabels(block); ok {
+			hasInteractive = true
+			interactiveLabels = append(interactiveLabels, labels...)
+		} else {
+			contentBlocks = append(contentBlocks, block)
+		}
+	}
+
+	if len(contentBlocks) == 1 && !alwaysWrap && !hasInteractive {
 		// don't wrap in <p> tag if there's only one block
-		text, _ := mc.renderSlackBlock(ctx, blocks.BlockSet[0], mentions)
+		text, _ := mc.renderSlackBlock(ctx, contentBlocks[0], mentions)
 		htmlText.WriteString(text)
 	} else {
 		var lastBlockWasUnsupported bool = false
-		for _, block := range blocks.BlockSet {
+		for _, block := range contentBlocks {
 			text, unsupported := mc.renderSlackBlock(ctx, block, mentions)
-			if !(unsupported && lastBlockWasUnsupported) {
+			if text == "" {
+				continue
+			} else if !(unsupported && lastBlockWasUnsupported) {
 				htmlText.WriteString(fmt.Sprintf("<p>%s</p>", text))
 			}
 			lastBlockWasUnsupported = unsupported
 		}
+		if hasInteractive {
+			escaped := make([]string, len(interactiveLabels))
+			for i, label := range interactiveLabels {
+				escaped[i] = html.EscapeString(label)
+			}
+			htmlText.WriteString(fmt.Sprintf("<p>%s</p>", renderInteractiveSummary(escaped)))
+		}
 	}
 
 	return htmlText.String()
@@ -467,6 +563,72 @@ func (mc *MessageConverter) trySlackBlocksToMatrix(ctx context.Context, portal *
 	return converted
 }
 
+const slackbotUserID = "USLACKBOT"
+
+// hasUnsupportedBlocks checks whether any of the given blocks would be rendered as unsupported by renderSlackBlock.
+func hasUnsupportedBlocks(blocks slack.Blocks) bool {
+	for _, block := range blocks.BlockSet {
+		switch b := block.(type) {
+		case *slack.HeaderBlock, *slack.DividerBlock, *slack.SectionBlock, *slack.RichTextBlock, *slack.FileBlock, *slack.CallBlock:
+		case *slack.ContextBlock:
+			for _, element := range b.ContextElements.Elements {
+				if _, ok := element.(*slack.TextBlockObject); !ok {
+					return true
+				}
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// renderCallBlock renders a call with the participant list from the call object in the event.
+// Call blocks parsed by slackgo only contain the call ID, participant changes are signaled by editing the message.
+func (mc *MessageConverter) renderCallBlock(ctx context.Context, block *slack.CallBlock) string {
+	call := getEventExtras(ctx).Calls[block.CallID]
+	if call == nil {
+		return "<i>Call</i>"
+	}
+	title := call.Name
+	if title == "" {
+		title = "Call"
+	}
+	var htmlText strings.Builder
+	htmlText.WriteString(fmt.Sprintf("<b>%s</b>", html.EscapeString(title)))
+	if call.HasEnded {
+		htmlText.WriteString(" (ended)")
+	}
+	names := make([]string, 0, len(call.ActiveParticipants))
+	for _, participant := range call.ActiveParticipants {
+		name := participant.DisplayName
+		if participant.SlackID != "" {
+			if _, ghostName := mc.GetMentionedUserInfo(ctx, participant.SlackID); ghostName != "" {
+				name = ghostName
+			}
+		}
+		if name != "" {
+			names = append(names, html.EscapeString(name))
+		}
+	}
+	if len(names) > 0 {
+		htmlText.WriteString(fmt.Sprintf("<br>Participants: %s", strings.Join(names, ", ")))
+	}
+	if !call.HasEnded && call.JoinURL != "" {
+		htmlText.WriteString(fmt.Sprintf(`<br><a href="%s">Join call</a>`, html.EscapeString(call.JoinURL)))
+	}
+	return htmlText.String()
+}
+
+func hasOnlyFileBlocks(blocks slack.Blocks) bool {
+	for _, block := range blocks.BlockSet {
+		if _, ok := block.(*slack.FileBlock); !ok {
+			return false
+		}
+	}
+	return len(blocks.BlockSet) > 0
+}
+
 func isImageAttachment(att *slack.Attachment) bool {
 	return att.Title == "" &&
 		att.Fields == nil &&
@@ -476,6 +638,15 @@ func isImageAttachment(att *slack.Attachment) bool {
 		att.Blocks.BlockSet[0].BlockType() == slack.MBTImage
 }
 
+// forwardAttribution returns the header naming the author of a forwarded message.
+// Quotes inside the forwarded message are rendered as plain quotes, as this header already attributes them.
+func (mc *MessageConverter) forwardAttribution(att *slack.Attachment) string {
+	if !mc.QuoteAttribution || att.AuthorName == "" {
+		return ""
+	}
+	return fmt.Sprintf("<b>%s</b><br>", html.EscapeString(att.AuthorName))
+}
+
 func (mc *MessageConverter) slackBlocksToMatrix(ctx context.Context, portal *bridgev2.Portal, intent bridgev2.MatrixAPI, blocks slack.Blocks, attachments []slack.Attachment) (*bridgev2.ConvertedMessagePart, error) {
 	// Special case for bots like the Giphy bot which send images in a specific format
 	if len(blocks.BlockSet) == 2 &&
@@ -504,10 +675,14 @@ func (mc *MessageConverter) slackBlocksToMatrix(ctx context.Context, portal *bri
 			continue
 		}
 		if attachment.IsMsgUnfurl {
+			link := attachment.FromURL
+			if matrixLink := mc.resolveMessagePermalink(ctx, portal, link); matrixLink != "" {
+				link = matrixLink
+			}
 			for _, message_block := range attachment.MessageBlocks {
 				renderedAttachment := mc.blocksToHTML(ctx, message_block.Message.Blocks, true, mentions)
-				htmlText.WriteString(fmt.Sprintf("<blockquote><b>%s</b><br>%s<a href=\"%s\"><i>%s</i></a><br></blockquote>",
-					attachment.AuthorName, renderedAttachment, attachment.FromURL, attachment.Footer))
+				htmlText.WriteString(fmt.Sprintf("<blockquote>%s%s<a href=\"%s\"><i>%s</i></a><br></blockquote>",
+					mc.forwardAttribution(&attachment), renderedAttachment, link, attachment.Footer))
 			}
 		} else if len(attachment.Blocks.BlockSet) > 0 {
 			for _, message_block := range attachment.Blocks.BlockSet {
@@ -559,14 +734,17 @@ func (mc *MessageConverter) slackBlocksToMatrix(ctx context.Context, portal *bri
 			} else {
 				htmlText.WriteString("<br>")
 			}
+			if len(attachment.Actions) > 0 {
+				htmlText.WriteString(renderAttachmentActions(attachment.Actions))
+				htmlText.WriteString("<br>")
+			}
 			var footerParts []string
 			if len(attachment.Footer) > 0 {
 				footerParts = append(footerParts, mc.mrkdwnToMatrixHtml(ctx, attachment.Footer, mentions))
 			}
 			if len(attachment.Ts) > 0 {
 				ts, _ := attachment.Ts.Int64()
-				t := time.Unix(ts, 0)
-				footerParts = append(footerParts, t.Local().Format("Jan 02, 2006 15:04:05 MST"))
+				footerParts = append(footerParts, mc.renderTimestamp(time.Unix(ts, 0)))
 			}
 			if len(footerParts) > 0 {
 				htmlText.WriteString(fmt.Sprintf("<sup>%s</sup>", strings.Join(footerParts, " | ")))
@@ -583,3 +761,14 @@ func (mc *MessageConverter) slackBlocksToMatrix(ctx context.Context, portal *bri
 		Content: &content,
 	}, nil
 }
+
+// renderTimestamp formats the given time in the configured timezone and wraps it in a <time> element,
+// so that clients which support it can show the time in the user's own locale.
+func (mc *MessageConverter) renderTimestamp(t time.Time) string {
+	loc := mc.Timezone
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	return fmt.Sprintf(`<time datetime="%s">%s</time>`, t.Format(time.RFC3339), html.EscapeString(t.Format("Jan 2, 2006 at 15:04 MST")))
+}
@@ -0,0 +1,149 @@
+// mautrix-slack - A Matrix-Slack puppeting bridge.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package msgconv
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/mautrix-slack/pkg/msgconv/mrkdwn"
+)
+
+func textSection(text string) *slack.RichTextSection {
+	return slack.NewRichTextSection(slack.NewRichTextSectionTextElement(text, nil))
+}
+
+func quote(border int, text string) *slack.RichTextQuote {
+	return &slack.RichTextQuote{
+		Type:     slack.RTEQuote,
+		Elements: textSection(text).Elements,
+		Border:   border,
+	}
+}
+
+func list(style slack.RichTextListElementType, indent, border int, items ...string) *slack.RichTextList {
+	sections := make([]slack.RichTextSection, len(items))
+	for i, item := range items {
+		sections[i] = *textSection(item)
+	}
+	return &slack.RichTextList{
+		Type:     slack.RTEList,
+		Elements: sections,
+		Style:    style,
+		Indent:   indent,
+		Border:   border,
+	}
+}
+
+func TestRenderSlackRichTextElements(t *testing.T) {
+	type testCase struct {
+		name     string
+		input    []slack.RichTextElement
+		expected string
+	}
+	testCases := []testCase{
+		{"QuoteListQuote", []slack.RichTextElement{
+			quote(0, "Notes"),
+			list(slack.RTEListBullet, 0, 1, "a", "b"),
+			quote(0, "End"),
+		}, "<blockquote><p>Notes</p><ul><li>a</li><li>b</li></ul><p>End</p></blockquote>"},
+		{"ListThenQuotedList", []slack.RichTextElement{
+			list(slack.RTEListBullet, 0, 0, "x"),
+			list(slack.RTEListBullet, 0, 1, "y"),
+		}, "<ul><li>x</li></ul><blockquote><ul><li>y</li></ul></blockquote>"},
+		{"QuoteNestedListQuote", []slack.RichTextElement{
+			quote(0, "Agenda"),
+			list(slack.RTEListOrdered, 0, 1, "one"),
+			list(slack.RTEListBullet, 1, 1, "detail"),
+			quote(0, "Done"),
+		}, `<blockquote><p>Agenda</p><ol type="1"><li>one<ul><li>detail</li></ul></li></ol><p>Done</p></blockquote>`},
+		{"MixedStylesAtNestedIndent", []slack.RichTextElement{
+			list(slack.RTEListOrdered, 0, 0, "1"),
+			list(slack.RTEListBullet, 1, 0, "a"),
+			list(slack.RTEListOrdered, 1, 0, "b"),
+		}, `<ol type="1"><li>1<ul><li>a</li></ul><ol type="a"><li>b</li></ol></li></ol>`},
+		{"DoubleQuote", []slack.RichTextElement{
+			quote(0, "outer"),
+			quote(1, "inner"),
+		}, "<blockquote><p>outer</p><blockquote>inner</blockquote></blockquote>"},
+	}
+	mc := &MessageConverter{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+			mc.renderSlackRichTextElements(context.Background(), tc.input, &event.Mentions{}, 0, &out)
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+}
+
+func TestBlocksToHTMLSummarizesInteractiveBlocks(t *testing.T) {
+	mc := &MessageConverter{}
+	blocks := slack.Blocks{BlockSet: []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.PlainTextType, "Deploy finished", false, false), nil, nil),
+		slack.NewActionBlock("",
+			slack.NewButtonBlockElement("approve", "", slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false)),
+			slack.NewButtonBlockElement("reject", "", slack.NewTextBlockObject(slack.PlainTextType, "Reject", false, false)),
+		),
+	}}
+	out := mc.blocksToHTML(context.Background(), blocks, false, &event.Mentions{})
+	assert.Equal(t, "<p>Deploy finished</p><p><i>Interactive elements only available on Slack: Approve, Reject</i></p>", out)
+}
+
+func TestRenderSlackRichTextQuoteAttribution(t *testing.T) {
+	mc := &MessageConverter{QuoteAttribution: true}
+	elements := []slack.RichTextElement{quote(0, "hello")}
+	var plain strings.Builder
+	mc.renderSlackRichTextElements(context.Background(), elements, &event.Mentions{}, 0, &plain)
+	assert.Equal(t, "<blockquote>hello</blockquote>", plain.String())
+
+	assert.Equal(t, "<b>Alice &amp; Bob</b><br>", mc.forwardAttribution(&slack.Attachment{AuthorName: "Alice & Bob"}))
+	assert.Equal(t, "", mc.forwardAttribution(&slack.Attachment{}))
+	mc.QuoteAttribution = false
+	assert.Equal(t, "", mc.forwardAttribution(&slack.Attachment{AuthorName: "Alice"}))
+}
+
+func TestRenderAttachmentActions(t *testing.T) {
+	actions := []slack.AttachmentAction{
+		{Name: "ack", Text: "Acknowledge", Type: slack.ActionType("button")},
+		{Name: "docs", Text: "Runbook", Type: slack.ActionType("button"), URL: "https://example.com/runbook"},
+	}
+	assert.Equal(t,
+		`<i>Interactive elements only available on Slack: Acknowledge, <a href="https://example.com/runbook">Runbook</a></i>`,
+		renderAttachmentActions(actions))
+}
+
+func TestBroadcastMentionRenderingMatchesMrkdwn(t *testing.T) {
+	mc := &MessageConverter{}
+	mc.SlackMrkdwnParser = mrkdwn.New(&mrkdwn.Params{SuppressRoomPing: roomPingSuppressed})
+	broadcast := []slack.RichTextSectionElement{slack.NewRichTextSectionBroadcastElement("channel")}
+	for _, suppress := range []bool{false, true} {
+		ctx := context.WithValue(context.Background(), contextKeySuppressRoomPing, suppress)
+		richTextMentions := &event.Mentions{}
+		richText := mc.renderRichTextSectionElements(ctx, broadcast, richTextMentions)
+		mrkdwnMentions := &event.Mentions{}
+		mrkdwnText := mc.mrkdwnToMatrixHtml(ctx, "<!channel>", mrkdwnMentions)
+		assert.Equal(t, richText, mrkdwnText)
+		assert.Equal(t, !suppress, richTextMentions.Room)
+		assert.Equal(t, richTextMentions.Room, mrkdwnMentions.Room)
+	}
+}
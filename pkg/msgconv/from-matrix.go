@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"net/http"
 	"strings"
 
 	"github.com/rs/zerolog"
@@ -40,6 +41,7 @@ var (
 	ErrMediaUploadFailed    = errors.New("failed to reupload media")
 	ErrMediaConvertFailed   = errors.New("failed to re-encode media")
 	ErrMediaOnlyEditCaption = errors.New("only media message caption can be edited")
+	ErrEmptyMessage         = bridgev2.WrapErrorInStatus(errors.New("can't send empty message")).WithErrorAsMessage().WithIsCertain(true).WithSendNotice(true)
 )
 
 func isMediaMsgtype(msgType event.MessageType) bool {
@@ -99,12 +101,16 @@ func (mc *MessageConverter) ToSlack(
 	switch content.MsgType {
 	case event.MsgText, event.MsgEmote, event.MsgNotice:
 		options := make([]slack.MsgOption, 0, 4)
-		var block slack.Block
+		var block *slack.RichTextBlock
 		if content.Format == event.FormatHTML {
 			block = mc.MatrixHTMLParser.Parse(ctx, content.FormattedBody, content.Mentions, portal)
 		} else {
 			block = mc.MatrixHTMLParser.ParseText(ctx, content.Body, content.Mentions, portal)
 		}
+		if isEmptyRichText(block) {
+			// Slack would either reject the message or show a blank message
+			return nil, ErrEmptyMessage
+		}
 		options = append(options, slack.MsgOptionBlocks(block))
 		if editTargetID != "" {
 			options = append(options, slack.MsgOptionUpdate(editTargetID))
@@ -114,11 +120,15 @@ func (mc *MessageConverter) ToSlack(
 		if content.MsgType == event.MsgEmote {
 			options = append(options, slack.MsgOptionMeMessage())
 		}
-		if content.BeeperLinkPreviews != nil && len(content.BeeperLinkPreviews) == 0 {
+		if shouldDisableUnfurl(content) {
 			options = append(options, slack.MsgOptionDisableLinkUnfurl(), slack.MsgOptionDisableMediaUnfurl())
 		}
 		if origSender != nil {
-			options = append(options, slack.MsgOptionUsername(origSender.FormattedName))
+			username := origSender.FormattedName
+			if mc.FormatRelayUsername != nil {
+				username = mc.FormatRelayUsername(origSender)
+			}
+			options = append(options, slack.MsgOptionUsername(username))
 			urlProvider, ok := mc.Bridge.Matrix.(bridgev2.MatrixConnectorWithPublicMedia)
 			if ok && origSender.AvatarURL != "" {
 				publicAvatarURL := urlProvider.GetPublicMediaAddress(origSender.AvatarURL)
@@ -143,6 +153,16 @@ func (mc *MessageConverter) ToSlack(
 			caption = content.Body
 			captionHTML = content.FormattedBody
 		}
+		if content.Info == nil {
+			content.Info = &event.FileInfo{}
+		}
+		if content.Info.MimeType == "" {
+			content.Info.MimeType = http.DetectContentType(data)
+		}
+		// The raw bytes are uploaded as-is, but Slack only animates GIFs that have the correct extension
+		if content.Info.MimeType == "image/gif" && !strings.HasSuffix(strings.ToLower(filename), ".gif") {
+			filename += ".gif"
+		}
 		if content.MSC3245Voice != nil && ffmpeg.Supported() {
 			data, err = ffmpeg.ConvertBytes(ctx, data, ".webm", []string{}, []string{"-c:a", "copy"}, content.Info.MimeType)
 			if err != nil {
@@ -207,6 +227,51 @@ func (mc *MessageConverter) ToSlack(
 	}
 }
 
+// shouldDisableUnfurl checks whether link previews should be disabled for a message, either because
+// the sender explicitly opted out of previews or because the message contains spoilers.
+func shouldDisableUnfurl(content *event.MessageEventContent) bool {
+	if content.BeeperLinkPreviews != nil && len(content.BeeperLinkPreviews) == 0 {
+		return true
+	}
+	return content.Format == event.FormatHTML && strings.Contains(content.FormattedBody, "data-mx-spoiler")
+}
+
+// isEmptyRichText checks if the block doesn't contain anything visible.
+// Mentions, links, emojis and other non-text elements are always considered content.
+func isEmptyRichText(block *slack.RichTextBlock) bool {
+	if block == nil {
+		return true
+	}
+	sectionIsEmpty := func(elements []slack.RichTextSectionElement) bool {
+		for _, elem := range elements {
+			text, ok := elem.(*slack.RichTextSectionTextElement)
+			if !ok || strings.TrimSpace(text.Text) != "" {
+				return false
+			}
+		}
+		return true
+	}
+	for _, elem := range block.Elements {
+		switch typedElem := elem.(type) {
+		case *slack.RichTextSection:
+			if !sectionIsEmpty(typedElem.Elements) {
+				return false
+			}
+		case *slack.RichTextQuote:
+			if !sectionIsEmpty(typedElem.Elements) {
+				return false
+			}
+		case *slack.RichTextPreformatted:
+			if !sectionIsEmpty(typedElem.Elements) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func (mc *MessageConverter) uploadMedia(ctx context.Context, portal *bridgev2.Portal, intent bridgev2.MatrixAPI, data []byte, content *event.MessageEventContent) error {
 	content.Info.Size = len(data)
 	if content.Info.Width == 0 && content.Info.Height == 0 && strings.HasPrefix(content.Info.MimeType, "image/") {
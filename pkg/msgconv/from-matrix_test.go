@@ -0,0 +1,50 @@
+// mautrix-slack - A Matrix-Slack puppeting bridge.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package msgconv
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"maunium.net/go/mautrix/event"
+)
+
+func TestIsEmptyRichText(t *testing.T) {
+	assert.True(t, isEmptyRichText(nil))
+	assert.True(t, isEmptyRichText(slack.NewRichTextBlock("")))
+	assert.True(t, isEmptyRichText(slack.NewRichTextBlock("", textSection(" \n "), quote(0, ""))))
+	assert.False(t, isEmptyRichText(slack.NewRichTextBlock("", textSection("hello"))))
+	assert.False(t, isEmptyRichText(slack.NewRichTextBlock("", slack.NewRichTextSection(
+		slack.NewRichTextSectionTextElement(" ", nil),
+		slack.NewRichTextSectionUserElement("U0123456", nil),
+	))))
+	assert.False(t, isEmptyRichText(slack.NewRichTextBlock("", list(slack.RTEListBullet, 0, 0, ""))))
+}
+
+func TestShouldDisableUnfurl(t *testing.T) {
+	assert.False(t, shouldDisableUnfurl(&event.MessageEventContent{Body: "https://example.com"}))
+	assert.True(t, shouldDisableUnfurl(&event.MessageEventContent{
+		Body:               "https://example.com",
+		BeeperLinkPreviews: []*event.BeeperLinkPreview{},
+	}))
+	assert.True(t, shouldDisableUnfurl(&event.MessageEventContent{
+		Body:          "https://example.com",
+		Format:        event.FormatHTML,
+		FormattedBody: `<span data-mx-spoiler>https://example.com</span>`,
+	}))
+}
@@ -21,10 +21,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html"
 	"image"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
@@ -41,6 +46,7 @@ import (
 	"maunium.net/go/mautrix/bridgev2/networkid"
 	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/mautrix-slack/pkg/slackid"
 )
@@ -54,13 +60,23 @@ func (mc *MessageConverter) ToMatrix(
 ) *bridgev2.ConvertedMessage {
 	ctx = context.WithValue(ctx, contextKeyPortal, portal)
 	ctx = context.WithValue(ctx, contextKeySource, source)
+	suppressRoomPing := mc.shouldSuppressRoomPing(portal)
+	ctx = context.WithValue(ctx, contextKeySuppressRoomPing, suppressRoomPing)
 	client := source.Client.(SlackClientProvider).GetClient()
 	output := &bridgev2.ConvertedMessage{}
+	teamID, channelID := slackid.ParsePortalID(portal.ID)
+	// This applies to all subtypes, including bot_message: bots and webhooks posting into threads
+	// have thread_ts set just like users, so they must not get a separate threading path.
 	if msg.ThreadTimestamp != "" && msg.ThreadTimestamp != msg.Timestamp {
-		teamID, channelID := slackid.ParsePortalID(portal.ID)
+		// Only the root is set here: bridgev2 finds the thread tail (fallback reply target) from the database.
+		// The root object with latest_reply is only included in thread_broadcast events from the Events API,
+		// which aren't routed into this path (socket mode message events aren't converted to RTM events).
 		output.ThreadRoot = ptr.Ptr(slackid.MakeMessageID(teamID, channelID, msg.ThreadTimestamp))
 	}
 	textPart := mc.makeTextPart(ctx, msg, portal, intent)
+	if link := getCrossChannelReplyLink(msg, channelID); link != "" {
+		textPart = addCrossChannelReplyQuote(textPart, link)
+	}
 	if textPart != nil {
 		output.Parts = append(output.Parts, textPart)
 	}
@@ -72,6 +88,7 @@ func (mc *MessageConverter) ToMatrix(
 		partID := slackid.MakePartID(slackid.PartTypeFile, i, file.ID)
 		output.Parts = append(output.Parts, mc.slackFileToMatrix(ctx, portal, intent, client, partID, &file))
 	}
+	output.Parts = append(output.Parts, mc.fileBlocksToMatrix(ctx, portal, intent, client, msg)...)
 	for i, att := range msg.Attachments {
 		if !isImageAttachment(&att) {
 			continue
@@ -89,15 +106,29 @@ func (mc *MessageConverter) ToMatrix(
 			CaptionMerged: true,
 		}
 	}
-	if msg.Username != "" {
+	if msg.ClientMsgID != "" && len(output.Parts) > 0 {
+		meta, ok := output.Parts[0].DBMetadata.(*slackid.MessageMetadata)
+		if !ok {
+			meta = &slackid.MessageMetadata{}
+			output.Parts[0].DBMetadata = meta
+		}
+		meta.ClientMsgID = msg.ClientMsgID
+	}
+	if len(output.Parts) > 0 {
+		ensureSelfMention(source, msg, output.Parts[0])
+	}
+	if suppressRoomPing {
 		for _, part := range output.Parts {
-			// TODO reupload avatar
-			part.Content.BeeperPerMessageProfile = &event.BeeperPerMessageProfile{
-				ID:          msg.Username,
-				Displayname: msg.Username,
+			if part.Content.Mentions != nil {
+				part.Content.Mentions.Room = false
 			}
 		}
 	}
+	if profile := mc.makePerMessageProfile(ctx, msg); profile != nil {
+		for _, part := range output.Parts {
+			part.Content.BeeperPerMessageProfile = profile
+		}
+	}
 	return output
 }
 
@@ -112,6 +143,8 @@ func (mc *MessageConverter) EditToMatrix(
 ) *bridgev2.ConvertedEdit {
 	ctx = context.WithValue(ctx, contextKeyPortal, portal)
 	ctx = context.WithValue(ctx, contextKeySource, source)
+	suppressRoomPing := mc.shouldSuppressRoomPing(portal)
+	ctx = context.WithValue(ctx, contextKeySuppressRoomPing, suppressRoomPing)
 	client := source.Client.(SlackClientProvider).GetClient()
 	output := &bridgev2.ConvertedEdit{}
 	existingMap := make(map[networkid.PartID]*database.Message, len(existing))
@@ -128,6 +161,11 @@ func (mc *MessageConverter) EditToMatrix(
 			}
 		}
 	}
+	if mc.RedactEmptyEdits && isEmptySlackMessage(msg) {
+		// Slack shows messages with all content removed as deleted, so redact every part instead of editing
+		output.DeletedParts = existing
+		return output
+	}
 	editTargetPart := existing[0]
 	modifiedPart := mc.makeTextPart(ctx, msg, portal, intent)
 	captionMerged := false
@@ -142,7 +180,9 @@ func (mc *MessageConverter) EditToMatrix(
 			// For edits where there's either only one media part, or there was no text part,
 			// we'll need to fetch the first media part to merge it in
 			if !captionMerged && modifiedPart != nil && (len(msg.Files) == 1 || editTargetPart.PartID != "") {
-				if editTargetPart.PartID != "" {
+				if editTargetPart.PartID != "" && ok {
+					// Always target the original event of the part, otherwise redacting the message later
+					// would leave the edits behind.
 					editTargetPart = existingPart
 				}
 				filePart := mc.slackFileToMatrix(ctx, portal, intent, client, partID, &file)
@@ -151,22 +191,370 @@ func (mc *MessageConverter) EditToMatrix(
 					CaptionMerged: true,
 				}
 				captionMerged = true
+			} else if ok && !IsPendingFile(&file) && wasPendingFile(origMsg, file.ID) {
+				// The file finished processing on Slack, so replace the placeholder with the actual file
+				filePart := mc.slackFileToMatrix(ctx, portal, intent, client, partID, &file)
+				output.ModifiedParts = append(output.ModifiedParts, filePart.ToEditPart(existingPart))
 			}
 		}
 	}
-	if msg.Username != "" {
-		modifiedPart.Content.BeeperPerMessageProfile = &event.BeeperPerMessageProfile{
-			ID:          msg.Username,
-			Displayname: msg.Username,
-		}
-	}
 	// TODO this doesn't handle edits to captions in msg.Attachments gifs properly
 	if modifiedPart != nil {
+		if profile := mc.makePerMessageProfile(ctx, msg); profile != nil {
+			modifiedPart.Content.BeeperPerMessageProfile = profile
+		}
+		ensureSelfMention(source, msg, modifiedPart)
+		if suppressRoomPing && modifiedPart.Content.Mentions != nil {
+			modifiedPart.Content.Mentions.Room = false
+		}
 		output.ModifiedParts = append(output.ModifiedParts, modifiedPart.ToEditPart(editTargetPart))
 	}
 	return output
 }
 
+func (mc *MessageConverter) makePerMessageProfile(ctx context.Context, msg *slack.Msg) *event.BeeperPerMessageProfile {
+	if msg.Username == "" {
+		if mc.MarkAppMessages {
+			return mc.makeAppImpersonationProfile(ctx, msg)
+		}
+		return nil
+	}
+	profile := &event.BeeperPerMessageProfile{
+		ID:          msg.Username,
+		Displayname: msg.Username,
+	}
+	var iconURL string
+	if msg.Icons != nil && msg.Icons.IconURL != "" {
+		iconURL = msg.Icons.IconURL
+	} else if msg.BotProfile != nil && msg.BotProfile.Icons != nil {
+		iconURL = msg.BotProfile.Icons.Image72
+	}
+	if iconURL != "" {
+		if mxc := mc.getBotIcon(ctx, iconURL); mxc != "" {
+			profile.AvatarURL = &mxc
+		}
+	}
+	return profile
+}
+
+// makeAppImpersonationProfile returns a per-message profile that attributes the message to the app
+// if it was posted by an app on behalf of a user (as_user), or nil for normal messages.
+func (mc *MessageConverter) makeAppImpersonationProfile(ctx context.Context, msg *slack.Msg) *event.BeeperPerMessageProfile {
+	if msg.BotID == "" || msg.User == "" {
+		return nil
+	}
+	source := ctx.Value(contextKeySource).(*bridgev2.UserLogin)
+	teamID, _ := slackid.ParseUserLoginID(source.ID)
+	ghost, err := mc.Bridge.GetExistingGhostByID(ctx, slackid.MakeUserID(teamID, msg.User))
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to get ghost to check for app impersonation")
+		return nil
+	} else if ghost == nil || ghost.Name == "" {
+		// The user info hasn't been synced yet, so it's not known whether the user is a bot
+		// and there's no name to label the message with.
+		return nil
+	} else if ghost.IsBot {
+		// Bot users always have a bot ID in their messages, only real users can be impersonated
+		return nil
+	}
+	appName := "an app"
+	if msg.BotProfile != nil && msg.BotProfile.Name != "" {
+		appName = msg.BotProfile.Name
+	}
+	profile := &event.BeeperPerMessageProfile{
+		ID:          "app-" + msg.BotID,
+		Displayname: fmt.Sprintf("%s (via %s)", ghost.Name, appName),
+	}
+	if ghost.AvatarMXC != "" {
+		profile.AvatarURL = &ghost.AvatarMXC
+	}
+	return profile
+}
+
+// botIconCacheSize is the maximum number of reuploaded bot icons to remember.
+const botIconCacheSize = 1024
+
+// botIconReuploadTimeout is the maximum time a shared bot icon reupload can take.
+const botIconReuploadTimeout = 2 * time.Minute
+
+type botIconCacheEntry struct {
+	url string
+	mxc id.ContentURIString
+}
+
+func (mc *MessageConverter) getCachedBotIcon(iconURL string) (id.ContentURIString, bool) {
+	mc.botIconCacheLock.Lock()
+	defer mc.botIconCacheLock.Unlock()
+	elem, ok := mc.botIconCache[iconURL]
+	if !ok {
+		return "", false
+	}
+	mc.botIconLRU.MoveToFront(elem)
+	return elem.Value.(*botIconCacheEntry).mxc, true
+}
+
+func (mc *MessageConverter) cacheBotIcon(iconURL string, mxc id.ContentURIString) {
+	mc.botIconCacheLock.Lock()
+	defer mc.botIconCacheLock.Unlock()
+	if elem, ok := mc.botIconCache[iconURL]; ok {
+		elem.Value.(*botIconCacheEntry).mxc = mxc
+		mc.botIconLRU.MoveToFront(elem)
+		return
+	}
+	mc.botIconCache[iconURL] = mc.botIconLRU.PushFront(&botIconCacheEntry{url: iconURL, mxc: mxc})
+	if mc.botIconLRU.Len() > botIconCacheSize {
+		oldest := mc.botIconLRU.Back()
+		mc.botIconLRU.Remove(oldest)
+		delete(mc.botIconCache, oldest.Value.(*botIconCacheEntry).url)
+	}
+}
+
+// getBotIcon reuploads a bot or webhook icon to Matrix. The results are cached by URL,
+// as bots tend to send lots of messages with the same icon. Concurrent requests for the
+// same URL share one reupload, and the cache lock isn't held during the reupload itself.
+func (mc *MessageConverter) getBotIcon(ctx context.Context, iconURL string) id.ContentURIString {
+	if mxc, ok := mc.getCachedBotIcon(iconURL); ok {
+		return mxc
+	}
+	res, _, _ := mc.botIconReuploads.Do(iconURL, func() (any, error) {
+		// The reupload is shared by all callers, so it mustn't be canceled if the first caller's context is
+		ctx, cancel := context.WithTimeout(zerolog.Ctx(ctx).WithContext(context.Background()), botIconReuploadTimeout)
+		defer cancel()
+		data, err := mc.downloadExternalImage(ctx, iconURL)
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Str("icon_url", iconURL).Msg("Failed to download bot icon")
+			return id.ContentURIString(""), nil
+		}
+		mxc, _, err := mc.Bridge.Bot.UploadMedia(ctx, "", data, "", http.DetectContentType(data))
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Str("icon_url", iconURL).Msg("Failed to reupload bot icon")
+			return id.ContentURIString(""), nil
+		}
+		mc.cacheBotIcon(iconURL, mxc)
+		return mxc, nil
+	})
+	return res.(id.ContentURIString)
+}
+
+// isEmptySlackMessage checks whether a message has no text, blocks, attachments or non-deleted files left.
+func isEmptySlackMessage(msg *slack.Msg) bool {
+	if strings.TrimSpace(msg.Text) != "" || len(msg.Blocks.BlockSet) > 0 || len(msg.Attachments) > 0 {
+		return false
+	}
+	return !slices.ContainsFunc(msg.Files, func(file slack.File) bool {
+		return file.Mode != "tombstone"
+	})
+}
+
+// shouldSuppressRoomPing checks whether @channel and similar mentions should be bridged
+// as plain text rather than @room pings, based on the member count of the channel.
+func (mc *MessageConverter) shouldSuppressRoomPing(portal *bridgev2.Portal) bool {
+	return mc.RoomMentionMemberLimit > 0 && portal.Metadata.(*slackid.PortalMetadata).MemberCount > mc.RoomMentionMemberLimit
+}
+
+func roomPingSuppressed(ctx context.Context) bool {
+	suppress, _ := ctx.Value(contextKeySuppressRoomPing).(bool)
+	return suppress
+}
+
+// resolveMessagePermalink converts a Slack message permalink into a matrix.to link to the bridged event,
+// so that quotes of bridged messages point back to Matrix. Returns an empty string if the message isn't bridged.
+func (mc *MessageConverter) resolveMessagePermalink(ctx context.Context, portal *bridgev2.Portal, permalink string) string {
+	channelID, timestamp, ok := parseMessagePermalink(permalink)
+	if !ok {
+		return ""
+	}
+	teamID, _ := slackid.ParsePortalID(portal.ID)
+	msg, err := mc.Bridge.DB.Message.GetFirstPartByID(ctx, portal.Receiver, slackid.MakeMessageID(teamID, channelID, timestamp))
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Str("permalink", permalink).Msg("Failed to get quoted message from database")
+		return ""
+	} else if msg == nil || msg.HasFakeMXID() {
+		return ""
+	}
+	msgPortal, err := mc.Bridge.GetExistingPortalByKey(ctx, msg.Room)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Str("permalink", permalink).Msg("Failed to get portal of quoted message")
+		return ""
+	} else if msgPortal == nil || msgPortal.MXID == "" {
+		return ""
+	}
+	return msgPortal.MXID.EventURI(msg.MXID, mc.ServerName).MatrixToURL()
+}
+
+// parseMessagePermalink extracts the channel ID and message timestamp from a Slack message permalink.
+func parseMessagePermalink(permalink string) (channelID, timestamp string, ok bool) {
+	parsed, err := url.Parse(permalink)
+	if err != nil || !strings.HasSuffix(parsed.Host, "slack.com") {
+		return
+	}
+	pathParts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(pathParts) != 3 || pathParts[0] != "archives" || len(pathParts[2]) <= 7 || pathParts[2][0] != 'p' {
+		return
+	}
+	channelID = pathParts[1]
+	timestampWithoutDot := pathParts[2][1:]
+	timestamp = timestampWithoutDot[:len(timestampWithoutDot)-6] + "." + timestampWithoutDot[len(timestampWithoutDot)-6:]
+	return channelID, timestamp, true
+}
+
+// getCrossChannelReplyLink finds a shared message attachment pointing at a message in a different channel,
+// which integrations use to reply to messages in other channels. A reply relation can't point outside the room,
+// so the permalink is returned to be rendered as a quote instead.
+func getCrossChannelReplyLink(msg *slack.Msg, channelID string) string {
+	for _, att := range msg.Attachments {
+		if !att.IsMsgUnfurl {
+			continue
+		}
+		sharedChannelID, _, ok := parseMessagePermalink(att.FromURL)
+		if ok && sharedChannelID != channelID {
+			return att.FromURL
+		}
+	}
+	return ""
+}
+
+func addCrossChannelReplyQuote(part *bridgev2.ConvertedMessagePart, link string) *bridgev2.ConvertedMessagePart {
+	if part == nil {
+		part = &bridgev2.ConvertedMessagePart{
+			Type:    event.EventMessage,
+			Content: &event.MessageEventContent{MsgType: event.MsgText},
+		}
+	}
+	part.Content.EnsureHasHTML()
+	part.Content.Body = strings.TrimSpace(fmt.Sprintf("> In reply to a message in another channel: %s\n\n%s", link, part.Content.Body))
+	part.Content.FormattedBody = fmt.Sprintf(
+		`<blockquote>In reply to <a href="%s">a message in another channel</a></blockquote>%s`,
+		link, part.Content.FormattedBody,
+	)
+	return part
+}
+
+// ensureSelfMention adds the logged-in user to m.mentions if the Slack message mentions them anywhere.
+// The individual block and mrkdwn renderers already add mentions, but not every rendering path
+// (e.g. fallbacks and special-cased blocks) goes through them.
+func ensureSelfMention(source *bridgev2.UserLogin, msg *slack.Msg, part *bridgev2.ConvertedMessagePart) {
+	_, userID := slackid.ParseUserLoginID(source.ID)
+	if userID == "" || source.UserMXID == "" || !messageMentionsUser(msg, userID) {
+		return
+	}
+	if part.Content.Mentions == nil {
+		part.Content.Mentions = &event.Mentions{}
+	}
+	part.Content.Mentions.Add(source.UserMXID)
+}
+
+// mrkdwnMentionsUser checks if mrkdwn text contains a mention of the given user, either as a plain
+// <@U123> tag or with a label like <@U123|name>. Checking just the prefix would match longer IDs too.
+func mrkdwnMentionsUser(text, userID string) bool {
+	return strings.Contains(text, "<@"+userID+">") || strings.Contains(text, "<@"+userID+"|")
+}
+
+func messageMentionsUser(msg *slack.Msg, userID string) bool {
+	if mrkdwnMentionsUser(msg.Text, userID) || blocksMentionUser(msg.Blocks, userID) {
+		return true
+	}
+	for _, att := range msg.Attachments {
+		if mrkdwnMentionsUser(att.Pretext, userID) ||
+			mrkdwnMentionsUser(att.Text, userID) ||
+			mrkdwnMentionsUser(att.Fallback, userID) ||
+			blocksMentionUser(att.Blocks, userID) {
+			return true
+		}
+	}
+	return false
+}
+
+func blocksMentionUser(blocks slack.Blocks, userID string) bool {
+	textMentions := func(text *slack.TextBlockObject) bool {
+		return text != nil && mrkdwnMentionsUser(text.Text, userID)
+	}
+	for _, block := range blocks.BlockSet {
+		switch typedBlock := block.(type) {
+		case *slack.RichTextBlock:
+			for _, elem := range typedBlock.Elements {
+				if richTextElementMentionsUser(elem, userID) {
+					return true
+				}
+			}
+		case *slack.SectionBlock:
+			if textMentions(typedBlock.Text) {
+				return true
+			}
+			for _, field := range typedBlock.Fields {
+				if textMentions(field) {
+					return true
+				}
+			}
+		case *slack.ContextBlock:
+			for _, elem := range typedBlock.ContextElements.Elements {
+				if text, ok := elem.(*slack.TextBlockObject); ok && textMentions(text) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func richTextElementMentionsUser(elem slack.RichTextElement, userID string) bool {
+	sectionMentions := func(elements []slack.RichTextSectionElement) bool {
+		for _, inner := range elements {
+			if user, ok := inner.(*slack.RichTextSectionUserElement); ok && user.UserID == userID {
+				return true
+			}
+		}
+		return false
+	}
+	switch typedElem := elem.(type) {
+	case *slack.RichTextSection:
+		return sectionMentions(typedElem.Elements)
+	case *slack.RichTextQuote:
+		return sectionMentions(typedElem.Elements)
+	case *slack.RichTextPreformatted:
+		return sectionMentions(typedElem.Elements)
+	case *slack.RichTextList:
+		for _, section := range typedElem.Elements {
+			if sectionMentions(section.Elements) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fileBlocksToMatrix bridges files that are referenced by file blocks rather than included in the files array.
+func (mc *MessageConverter) fileBlocksToMatrix(ctx context.Context, portal *bridgev2.Portal, intent bridgev2.MatrixAPI, client *slack.Client, msg *slack.Msg) (parts []*bridgev2.ConvertedMessagePart) {
+	for i, block := range msg.Blocks.BlockSet {
+		fileBlock, ok := block.(*slack.FileBlock)
+		if !ok || fileBlock.ExternalID == "" {
+			continue
+		}
+		log := zerolog.Ctx(ctx).With().Str("file_external_id", fileBlock.ExternalID).Logger()
+		remoteFile, err := client.GetRemoteFileInfoContext(ctx, fileBlock.ExternalID, "")
+		if err != nil {
+			log.Err(err).Msg("Failed to resolve file block")
+			parts = append(parts, makeErrorMessage(slackid.MakePartID(slackid.PartTypeFile, len(msg.Files)+i, fileBlock.ExternalID), "Failed to fetch file"))
+			continue
+		}
+		alreadyBridged := slices.ContainsFunc(msg.Files, func(file slack.File) bool {
+			return file.ID == remoteFile.ID
+		})
+		if alreadyBridged {
+			continue
+		}
+		partID := slackid.MakePartID(slackid.PartTypeFile, len(msg.Files)+i, remoteFile.ID)
+		file, _, _, err := client.GetFileInfoContext(ctx, remoteFile.ID, 0, 0)
+		if err != nil || file == nil {
+			log.Err(err).Str("file_id", remoteFile.ID).Msg("Failed to fetch info of file in file block")
+			parts = append(parts, makeErrorMessage(partID, "Failed to fetch file"))
+			continue
+		}
+		parts = append(parts, mc.slackFileToMatrix(ctx, portal, intent, client, partID, file))
+	}
+	return
+}
+
 func (mc *MessageConverter) makeTextPart(ctx context.Context, msg *slack.Msg, portal *bridgev2.Portal, intent bridgev2.MatrixAPI) *bridgev2.ConvertedMessagePart {
 	var text string
 	if msg.Text != "" {
@@ -183,25 +571,107 @@ func (mc *MessageConverter) makeTextPart(ctx context.Context, msg *slack.Msg, po
 		}
 	}
 	var textPart *bridgev2.ConvertedMessagePart
-	if len(msg.Blocks.BlockSet) != 0 || len(msg.Attachments) != 0 {
+	if msg.SubType == "reminder_add" {
+		if what, when, ok := parseReminderText(msg.Text); ok {
+			textPart = mc.slackTextToMatrix(ctx, fmt.Sprintf("Reminder set: %s %s", what, when))
+			textPart.Content.MsgType = event.MsgNotice
+			return textPart
+		}
+	}
+	if (msg.SubType == slack.MsgSubTypeChannelName || msg.SubType == slack.MsgSubTypeGroupName) && msg.Name != "" {
+		body := fmt.Sprintf("renamed the channel to %s", msg.Name)
+		if msg.OldName != "" {
+			body = fmt.Sprintf("renamed the channel from %s to %s", msg.OldName, msg.Name)
+		}
+		return &bridgev2.ConvertedMessagePart{
+			Type: event.EventMessage,
+			Content: &event.MessageEventContent{
+				MsgType: event.MsgNotice,
+				Body:    body,
+			},
+		}
+	}
+	if msg.User == slackbotUserID && msg.Text != "" && len(msg.Attachments) == 0 && hasUnsupportedBlocks(msg.Blocks) {
+		// Slackbot onboarding messages use lots of interactive blocks that can't be bridged,
+		// but they always have a plain text version, which is more useful than a list of unsupported elements.
+		textPart = mc.slackTextToMatrix(ctx, msg.Text)
+	} else if len(msg.Blocks.BlockSet) != 0 || len(msg.Attachments) != 0 {
 		textPart = mc.trySlackBlocksToMatrix(ctx, portal, intent, msg.Blocks, msg.Attachments)
 	} else if text != "" {
 		textPart = mc.slackTextToMatrix(ctx, text)
 	}
+	if textPart != nil && textPart.Content.Body == "" && len(msg.Files) == 0 && hasOnlyFileBlocks(msg.Blocks) {
+		// File blocks are bridged as separate parts, so there's no text to send
+		textPart = nil
+	}
+	if textPart == nil && msg.SubType == "sh_room_created" {
+		// Huddle room messages usually don't have any text, only the room object
+		textPart = &bridgev2.ConvertedMessagePart{
+			Type: event.EventMessage,
+			Content: &event.MessageEventContent{
+				MsgType: event.MsgNotice,
+				Body:    "Started a huddle",
+			},
+		}
+	}
 	if textPart != nil {
 		switch msg.SubType {
 		case slack.MsgSubTypeMeMessage:
 			textPart.Content.MsgType = event.MsgEmote
-		case "huddle_thread":
-			teamID, channelID := slackid.ParsePortalID(portal.ID)
-			textPart.Content.EnsureHasHTML()
-			textPart.Content.Body += fmt.Sprintf("\n\nJoin via the Slack app: https://app.slack.com/client/%s/%s", teamID, channelID)
-			textPart.Content.FormattedBody += fmt.Sprintf(`<p><a href="https://app.slack.com/client/%s/%s">Click here to join via the Slack app</a></p>`, teamID, channelID)
+		case "huddle_thread", "sh_room_created":
+			room := getEventExtras(ctx).HuddleRoom
+			if room != nil {
+				mc.addHuddleParticipants(ctx, room, textPart.Content)
+			}
+			if room == nil || !room.HasEnded {
+				addHuddleJoinLink(portal, textPart.Content)
+			}
 		}
 	}
 	return textPart
 }
 
+var reminderTextRegex = regexp.MustCompile(`reminder [“"](.+)[”"](?: in this channel| for .+?)? ((?:at|on|in|every) .+?)\.?$`)
+
+// parseReminderText extracts the reminder text and time from the text of reminder_add messages,
+// e.g. `<@U123> set up a reminder “water plants” in this channel at 9AM tomorrow, Eastern Standard Time.`
+func parseReminderText(text string) (what, when string, ok bool) {
+	match := reminderTextRegex.FindStringSubmatch(text)
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], match[2], true
+}
+
+// addHuddleParticipants adds the current participant list of a huddle to the message.
+// Participant changes are bridged as edits of the huddle message.
+func (mc *MessageConverter) addHuddleParticipants(ctx context.Context, room *HuddleRoom, content *event.MessageEventContent) {
+	content.EnsureHasHTML()
+	if room.HasEnded {
+		content.Body += "\n\nThe huddle has ended"
+		content.FormattedBody += "<p><i>The huddle has ended</i></p>"
+		return
+	}
+	names := make([]string, 0, len(room.Participants))
+	for _, userID := range room.Participants {
+		if _, name := mc.GetMentionedUserInfo(ctx, userID); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	content.Body += "\n\nParticipants: " + strings.Join(names, ", ")
+	content.FormattedBody += fmt.Sprintf("<p>Participants: %s</p>", html.EscapeString(strings.Join(names, ", ")))
+}
+
+func addHuddleJoinLink(portal *bridgev2.Portal, content *event.MessageEventContent) {
+	teamID, channelID := slackid.ParsePortalID(portal.ID)
+	content.EnsureHasHTML()
+	content.Body += fmt.Sprintf("\n\nJoin via the Slack app: https://app.slack.com/client/%s/%s", teamID, channelID)
+	content.FormattedBody += fmt.Sprintf(`<p><a href="https://app.slack.com/client/%s/%s">Click here to join via the Slack app</a></p>`, teamID, channelID)
+}
+
 func (mc *MessageConverter) slackTextToMatrix(ctx context.Context, text string) *bridgev2.ConvertedMessagePart {
 	mentions := &event.Mentions{}
 	content := format.HTMLToContent(mc.mrkdwnToMatrixHtml(ctx, text, mentions))
@@ -241,6 +711,53 @@ func (dtwp *doctypeCheckingWriteProxy) Write(p []byte) (n int, err error) {
 	return dtwp.Writer.Write(p)
 }
 
+var errPublicPermalinkUnavailable = errors.New("public permalink unavailable")
+
+// downloadPublicPermalink downloads a file from its public permalink. If the file can't be fetched before
+// anything is written, the returned error wraps errPublicPermalinkUnavailable.
+func (mc *MessageConverter) downloadPublicPermalink(ctx context.Context, permalink string, dest io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, permalink, nil)
+	if err != nil {
+		return fmt.Errorf("failed to prepare request: %w", err)
+	}
+	resp, err := mc.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: failed to send request: %w", errPublicPermalinkUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: unexpected status code %d", errPublicPermalinkUnavailable, resp.StatusCode)
+	}
+	_, err = io.Copy(&doctypeCheckingWriteProxy{Writer: dest, isStart: true}, resp.Body)
+	if errors.Is(err, errHTMLFile) {
+		// Nothing is written if the response is an HTML page, which Slack returns instead of files that aren't public
+		return fmt.Errorf("%w: %w", errPublicPermalinkUnavailable, err)
+	}
+	return err
+}
+
+// downloadPublicSlackFile downloads a file that only has a public permalink (like some Slack Connect files).
+// If the permalink doesn't work, the file info is refetched to get an authenticated download URL instead.
+func (mc *MessageConverter) downloadPublicSlackFile(ctx context.Context, client *slack.Client, file *slack.File, dest io.Writer) error {
+	err := mc.downloadPublicPermalink(ctx, file.PermalinkPublic, dest)
+	if !errors.Is(err, errPublicPermalinkUnavailable) {
+		return err
+	}
+	zerolog.Ctx(ctx).Warn().Err(err).Str("file_id", file.ID).Msg("Public permalink unavailable, refetching file info")
+	freshFile, _, _, infoErr := client.GetFileInfoContext(ctx, file.ID, 0, 0)
+	if infoErr != nil {
+		return fmt.Errorf("%w (refetching file info failed: %w)", err, infoErr)
+	}
+	freshURL := freshFile.URLPrivateDownload
+	if freshURL == "" {
+		freshURL = freshFile.URLPrivate
+	}
+	if freshURL == "" {
+		return fmt.Errorf("%w (refetched file info has no download URL)", err)
+	}
+	return client.GetFileContext(ctx, freshURL, dest)
+}
+
 func (mc *MessageConverter) slackFileToMatrix(ctx context.Context, portal *bridgev2.Portal, intent bridgev2.MatrixAPI, client *slack.Client, partID networkid.PartID, file *slack.File) *bridgev2.ConvertedMessagePart {
 	log := zerolog.Ctx(ctx).With().Str("file_id", file.ID).Logger()
 	if file.FileAccess == "check_file_info" {
@@ -251,11 +768,15 @@ func (mc *MessageConverter) slackFileToMatrix(ctx context.Context, portal *bridg
 		}
 		file = connectFile
 	}
+	// Backfilled files can't be pending anymore, so only live messages get a placeholder.
+	// Waiting here would block the portal's event queue, so the connector replaces it with an edit once the file is ready.
+	if IsPendingFile(file) && !isBackfill(ctx) {
+		return makeErrorMessage(partID, "%s is still being processed by Slack", file.Name)
+	}
 	if file.Size > mc.MaxFileSize {
 		log.Debug().Int("file_size", file.Size).Msg("Dropping too large file")
 		return makeErrorMessage(partID, "Too large file (%d MB)", file.Size/1_000_000)
 	}
-	content := convertSlackFileMetadata(file)
 	var url string
 	if file.URLPrivateDownload != "" {
 		url = file.URLPrivateDownload
@@ -266,12 +787,77 @@ func (mc *MessageConverter) slackFileToMatrix(ctx context.Context, portal *bridg
 		log.Warn().Msg("No usable URL found in file object")
 		return makeErrorMessage(partID, "File URL not found")
 	}
+	var content event.MessageEventContent
+	var retErr *bridgev2.ConvertedMessagePart
+	var uploadErr error
+	maxRetries := mc.MediaUploadRetries
+	if isBackfill(ctx) {
+		maxRetries = 0
+	}
+	for attempt := 0; ; attempt++ {
+		// The upload callback modifies the content, so start from a clean copy on every attempt
+		content = convertSlackFileMetadata(file)
+		content.URL, content.File, uploadErr = mc.uploadSlackFileOnce(ctx, portal, intent, client, partID, file, &content, url, &retErr)
+		if uploadErr == nil || retErr != nil || attempt >= maxRetries || isTooLargeUploadError(uploadErr) {
+			break
+		}
+		delay := (2 * time.Second) << attempt
+		log.Warn().Err(uploadErr).
+			Int("attempt", attempt+1).
+			Stringer("retry_in", delay).
+			Msg("Failed to upload file to Matrix, retrying")
+		select {
+		case <-time.After(delay):
+			continue
+		case <-ctx.Done():
+		}
+		break
+	}
+	if uploadErr != nil {
+		if retErr != nil {
+			return retErr
+		}
+		if errors.Is(uploadErr, mautrix.MTooLarge) {
+			log.Err(uploadErr).Msg("Homeserver rejected too large file")
+		} else if httpErr := (mautrix.HTTPError{}); errors.As(uploadErr, &httpErr) && httpErr.IsStatus(413) {
+			log.Err(uploadErr).Msg("Proxy rejected too large file")
+		} else {
+			log.Err(uploadErr).Msg("Failed to upload file to Matrix")
+		}
+		return makeErrorMessage(partID, "Failed to transfer file")
+	}
+	// TODO use thumb_video for videos once slackgo exposes it, the thumb_N fields are only set for images
+	if content.MsgType == event.MsgImage {
+		mc.uploadSlackThumbnail(ctx, portal, intent, client, file, &content)
+	}
+	return &bridgev2.ConvertedMessagePart{
+		ID:      partID,
+		Type:    event.EventMessage,
+		Content: &content,
+	}
+}
+
+func isTooLargeUploadError(err error) bool {
+	httpErr := mautrix.HTTPError{}
+	return errors.Is(err, mautrix.MTooLarge) || (errors.As(err, &httpErr) && httpErr.IsStatus(413))
+}
+
+func (mc *MessageConverter) uploadSlackFileOnce(
+	ctx context.Context,
+	portal *bridgev2.Portal,
+	intent bridgev2.MatrixAPI,
+	client *slack.Client,
+	partID networkid.PartID,
+	file *slack.File,
+	content *event.MessageEventContent,
+	url string,
+	retErr **bridgev2.ConvertedMessagePart,
+) (id.ContentURIString, *event.EncryptedFileInfo, error) {
+	log := zerolog.Ctx(ctx).With().Str("file_id", file.ID).Logger()
 	convertAudio := file.SubType == "slack_audio" && ffmpeg.Supported()
 	needsMediaSize := content.Info.Width == 0 && content.Info.Height == 0 && strings.HasPrefix(content.Info.MimeType, "image/")
 	requireFile := convertAudio || needsMediaSize
-	var retErr *bridgev2.ConvertedMessagePart
-	var uploadErr error
-	content.URL, content.File, uploadErr = intent.UploadMediaStream(ctx, portal.MXID, int64(file.Size), requireFile, func(dest io.Writer) (res *bridgev2.FileStreamResult, err error) {
+	return intent.UploadMediaStream(ctx, portal.MXID, int64(file.Size), requireFile, func(dest io.Writer) (res *bridgev2.FileStreamResult, err error) {
 		res = &bridgev2.FileStreamResult{
 			ReplacementFile: "",
 			FileName:        file.Name,
@@ -285,16 +871,11 @@ func (mc *MessageConverter) slackFileToMatrix(ctx context.Context, portal *bridg
 				err = client.GetFileContext(ctx, url, dest)
 			}
 		} else if file.PermalinkPublic != "" {
-			var resp *http.Response
-			// TODO don't use DefaultClient and use context
-			resp, err = http.DefaultClient.Get(file.PermalinkPublic)
-			if err == nil {
-				_, err = io.Copy(dest, resp.Body)
-			}
+			err = mc.downloadPublicSlackFile(ctx, client, file, dest)
 		}
 		if err != nil {
 			log.Err(err).Msg("Failed to download file from Slack")
-			retErr = makeErrorMessage(partID, "Failed to download file from Slack")
+			*retErr = makeErrorMessage(partID, "Failed to download file from Slack")
 			return
 		}
 		if convertAudio {
@@ -309,29 +890,35 @@ func (mc *MessageConverter) slackFileToMatrix(ctx context.Context, portal *bridg
 			err = os.Rename(destFile.Name(), tempFileWithExt)
 			if err != nil {
 				log.Err(err).Msg("Failed to rename temp file")
-				retErr = makeErrorMessage(partID, "Failed to rename temp file")
+				*retErr = makeErrorMessage(partID, "Failed to rename temp file")
 				return
 			}
 			res.ReplacementFile, err = ffmpeg.ConvertPath(ctx, tempFileWithExt, ".ogg", []string{}, []string{"-c:a", "libopus"}, true)
 			if err != nil {
 				log.Err(err).Msg("Failed to convert voice message")
-				retErr = makeErrorMessage(partID, "Failed to convert voice message")
+				*retErr = makeErrorMessage(partID, "Failed to convert voice message")
 				return
 			}
 			content.Info.MimeType = "audio/ogg"
 			content.Body += ".ogg"
 			res.MimeType = "audio/ogg"
 			res.FileName += ".ogg"
-			if file.AudioWaveSamples == nil {
-				file.AudioWaveSamples = []int{}
-			}
+			waveform := make([]int, len(file.AudioWaveSamples))
 			for i, val := range file.AudioWaveSamples {
 				// Slack's waveforms are in the range 0-100, we need to convert them to 0-256
-				file.AudioWaveSamples[i] = min(int(float64(val)*2.56), 256)
+				waveform[i] = min(int(float64(val)*2.56), 256)
+			}
+			if len(waveform) == 0 {
+				waveform, err = generateWaveform(ctx, res.ReplacementFile)
+				if err != nil {
+					// Not fatal, the voice message is still usable without a waveform
+					log.Warn().Err(err).Msg("Failed to generate waveform for voice message")
+					err = nil
+				}
 			}
 			content.MSC1767Audio = &event.MSC1767Audio{
 				Duration: content.Info.Duration,
-				Waveform: file.AudioWaveSamples,
+				Waveform: waveform,
 			}
 			content.MSC3245Voice = &event.MSC3245Voice{}
 		} else if needsMediaSize {
@@ -344,24 +931,137 @@ func (mc *MessageConverter) slackFileToMatrix(ctx context.Context, portal *bridg
 		}
 		return
 	})
-	if uploadErr != nil {
-		if retErr != nil {
-			return retErr
-		}
-		if errors.Is(uploadErr, mautrix.MTooLarge) {
-			log.Err(uploadErr).Msg("Homeserver rejected too large file")
-		} else if httpErr := (mautrix.HTTPError{}); errors.As(uploadErr, &httpErr) && httpErr.IsStatus(413) {
-			log.Err(uploadErr).Msg("Proxy rejected too large file")
-		} else {
-			log.Err(uploadErr).Msg("Failed to upload file to Matrix")
+}
+
+const (
+	waveformSampleRate  = 8000
+	waveformBucketCount = 100
+)
+
+// generateWaveform decodes the given audio file with ffmpeg and returns its peak amplitudes
+// in the same 0-256 range that's used for Slack-provided waveforms.
+func generateWaveform(ctx context.Context, path string) ([]int, error) {
+	rawPath, err := ffmpeg.ConvertPath(ctx, path, ".raw", []string{}, []string{
+		"-f", "s16le", "-ac", "1", "-ar", strconv.Itoa(waveformSampleRate),
+	}, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode audio: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(rawPath)
+	}()
+	data, err := os.ReadFile(rawPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read decoded audio: %w", err)
+	}
+	return calculateWaveform(data, waveformBucketCount), nil
+}
+
+// calculateWaveform splits signed 16-bit little-endian mono PCM data into buckets and returns
+// the peak amplitude of each bucket, scaled to 0-256.
+func calculateWaveform(pcm []byte, buckets int) []int {
+	sampleCount := len(pcm) / 2
+	if sampleCount == 0 {
+		return nil
+	}
+	buckets = min(buckets, sampleCount)
+	waveform := make([]int, buckets)
+	for i := 0; i < sampleCount; i++ {
+		sample := int(int16(uint16(pcm[i*2]) | uint16(pcm[i*2+1])<<8))
+		if sample < 0 {
+			sample = -sample
 		}
-		return makeErrorMessage(partID, "Failed to transfer file")
+		bucket := i * buckets / sampleCount
+		waveform[bucket] = max(waveform[bucket], sample)
 	}
-	return &bridgev2.ConvertedMessagePart{
-		ID:      partID,
-		Type:    event.EventMessage,
-		Content: &content,
+	for i, peak := range waveform {
+		waveform[i] = min(peak*256/32768, 256)
+	}
+	return waveform
+}
+
+// uploadSlackThumbnail reuploads one of the thumbnails pre-generated by Slack and sets it as the Matrix thumbnail.
+// Failures are only logged, as the file itself has already been bridged.
+func (mc *MessageConverter) uploadSlackThumbnail(ctx context.Context, portal *bridgev2.Portal, intent bridgev2.MatrixAPI, client *slack.Client, file *slack.File, content *event.MessageEventContent) {
+	thumbURL, width, height := file.Thumb720, file.Thumb720W, file.Thumb720H
+	if thumbURL == "" {
+		thumbURL, width, height = file.Thumb360, file.Thumb360W, file.Thumb360H
+	}
+	if thumbURL == "" {
+		return
+	}
+	log := zerolog.Ctx(ctx).With().Str("file_id", file.ID).Logger()
+	var buf bytes.Buffer
+	err := client.GetFileContext(ctx, thumbURL, &doctypeCheckingWriteProxy{Writer: &buf})
+	if err != nil {
+		log.Err(err).Msg("Failed to download thumbnail from Slack")
+		return
+	}
+	data := buf.Bytes()
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		log.Warn().Str("mime_type", mimeType).Msg("Thumbnail from Slack isn't an image")
+		return
+	}
+	thumbInfo := &event.FileInfo{
+		MimeType: mimeType,
+		Size:     len(data),
+		Width:    width,
+		Height:   height,
+	}
+	if width == 0 || height == 0 {
+		cfg, _, _ := image.DecodeConfig(bytes.NewReader(data))
+		thumbInfo.Width, thumbInfo.Height = cfg.Width, cfg.Height
+	}
+	thumbURI, thumbFile, err := intent.UploadMedia(ctx, portal.MXID, data, "thumbnail"+exmime.ExtensionFromMimetype(mimeType), mimeType)
+	if err != nil {
+		log.Err(err).Msg("Failed to upload thumbnail to Matrix")
+		return
+	}
+	content.Info.ThumbnailURL = thumbURI
+	content.Info.ThumbnailFile = thumbFile
+	content.Info.ThumbnailInfo = thumbInfo
+}
+
+// IsPendingFile checks whether a file in a message event hasn't finished uploading or processing on Slack yet.
+// Files without URLs aren't necessarily pending (e.g. hidden_by_limit files), so only the mode is checked.
+func IsPendingFile(file *slack.File) bool {
+	return file.Mode == "pending"
+}
+
+// HasPendingFiles checks whether any of the files in a message haven't finished processing on Slack yet.
+func HasPendingFiles(msg *slack.Msg) bool {
+	return slices.ContainsFunc(msg.Files, func(file slack.File) bool {
+		return IsPendingFile(&file)
+	})
+}
+
+func wasPendingFile(origMsg *slack.Msg, fileID string) bool {
+	return origMsg != nil && slices.ContainsFunc(origMsg.Files, func(file slack.File) bool {
+		return file.ID == fileID && IsPendingFile(&file)
+	})
+}
+
+var pendingFileRetryDelays = []time.Duration{2 * time.Second, 5 * time.Second, 10 * time.Second, 20 * time.Second}
+
+// WaitForPendingFile polls the file info until Slack has finished processing the file.
+func WaitForPendingFile(ctx context.Context, client *slack.Client, fileID string) (*slack.File, error) {
+	log := zerolog.Ctx(ctx).With().Str("file_id", fileID).Logger()
+	for _, delay := range pendingFileRetryDelays {
+		log.Debug().Stringer("delay", delay).Msg("File is still pending, waiting before refetching info")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		file, _, _, err := client.GetFileInfoContext(ctx, fileID, 0, 0)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to refetch info of pending file")
+		} else if file != nil && !IsPendingFile(file) {
+			return file, nil
+		}
 	}
+	return nil, fmt.Errorf("file still pending after %d retries", len(pendingFileRetryDelays))
 }
 
 func convertSlackFileMetadata(file *slack.File) event.MessageEventContent {
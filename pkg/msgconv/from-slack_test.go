@@ -0,0 +1,143 @@
+// mautrix-slack - A Matrix-Slack puppeting bridge.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package msgconv
+
+import (
+	lru "container/list"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"maunium.net/go/mautrix/bridgev2"
+	"maunium.net/go/mautrix/bridgev2/database"
+	"maunium.net/go/mautrix/bridgev2/networkid"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/mautrix-slack/pkg/msgconv/mrkdwn"
+	"go.mau.fi/mautrix-slack/pkg/slackid"
+)
+
+func TestParseReminderText(t *testing.T) {
+	what, when, ok := parseReminderText("<@U0123456> set up a reminder “water plants” in this channel at 9AM tomorrow, Eastern Standard Time.")
+	assert.True(t, ok)
+	assert.Equal(t, "water plants", what)
+	assert.Equal(t, "at 9AM tomorrow, Eastern Standard Time", when)
+
+	what, when, ok = parseReminderText(`<@U0123456> set up a reminder "stand up" for <@U0654321> every weekday at 10AM.`)
+	assert.True(t, ok)
+	assert.Equal(t, "stand up", what)
+	assert.Equal(t, "every weekday at 10AM", when)
+
+	_, _, ok = parseReminderText("Something else entirely")
+	assert.False(t, ok)
+}
+
+func TestCalculateWaveform(t *testing.T) {
+	pcm := []byte{
+		0x00, 0x00, 0x00, 0x40, // 0, 16384
+		0x00, 0x80, 0x00, 0x00, // -32768, 0
+	}
+	assert.Equal(t, []int{128, 256}, calculateWaveform(pcm, 2))
+	assert.Nil(t, calculateWaveform(nil, 100))
+	assert.Len(t, calculateWaveform(pcm, 100), 4)
+}
+
+func TestMessageMentionsUser(t *testing.T) {
+	assert.True(t, messageMentionsUser(&slack.Msg{Text: "hi <@U123>"}, "U123"))
+	assert.True(t, messageMentionsUser(&slack.Msg{Text: "hi <@U123|alice>"}, "U123"))
+	assert.False(t, messageMentionsUser(&slack.Msg{Text: "hi <@U1234>"}, "U123"))
+	assert.False(t, messageMentionsUser(&slack.Msg{Text: "hi <@U1234|bob>"}, "U123"))
+	assert.True(t, messageMentionsUser(&slack.Msg{Attachments: []slack.Attachment{{Text: "cc <@U123>"}}}, "U123"))
+}
+
+type fakeSlackClientProvider struct {
+	bridgev2.NetworkAPI
+}
+
+func (fakeSlackClientProvider) GetClient() *slack.Client {
+	return nil
+}
+
+func (fakeSlackClientProvider) GetEmoji(context.Context, string) (string, bool) {
+	return "", false
+}
+
+func TestToMatrixBotMessageInThread(t *testing.T) {
+	mc := &MessageConverter{}
+	mc.SlackMrkdwnParser = mrkdwn.New(&mrkdwn.Params{})
+	portal := &bridgev2.Portal{Portal: &database.Portal{
+		PortalKey: networkid.PortalKey{ID: slackid.MakePortalID("T0123", "C0123")},
+		Metadata:  &slackid.PortalMetadata{},
+	}}
+	source := &bridgev2.UserLogin{
+		UserLogin: &database.UserLogin{ID: slackid.MakeUserLoginID("T0123", "U0123")},
+		Client:    fakeSlackClientProvider{},
+	}
+	msg := &slack.Msg{
+		Type:            slack.TYPE_MESSAGE,
+		SubType:         slack.MsgSubTypeBotMessage,
+		BotID:           "B0123",
+		Username:        "Monitoring",
+		Channel:         "C0123",
+		Text:            "Disk usage is above 90%",
+		Timestamp:       "1700000002.000000",
+		ThreadTimestamp: "1700000001.000000",
+	}
+	converted := mc.ToMatrix(context.Background(), portal, nil, source, msg)
+	if assert.NotNil(t, converted.ThreadRoot) {
+		assert.Equal(t, slackid.MakeMessageID("T0123", "C0123", "1700000001.000000"), *converted.ThreadRoot)
+	}
+	assert.Len(t, converted.Parts, 1)
+}
+
+func TestGetCrossChannelReplyLink(t *testing.T) {
+	otherChannelLink := "https://example.slack.com/archives/C0456/p1700000001000100?thread_ts=1700000000.000000&cid=C0456"
+	msg := &slack.Msg{Attachments: []slack.Attachment{{IsMsgUnfurl: true, FromURL: otherChannelLink}}}
+	assert.Equal(t, otherChannelLink, getCrossChannelReplyLink(msg, "C0123"))
+	assert.Equal(t, "", getCrossChannelReplyLink(msg, "C0456"))
+	msg.Attachments[0].IsMsgUnfurl = false
+	assert.Equal(t, "", getCrossChannelReplyLink(msg, "C0123"))
+
+	channelID, timestamp, ok := parseMessagePermalink(otherChannelLink)
+	assert.True(t, ok)
+	assert.Equal(t, "C0456", channelID)
+	assert.Equal(t, "1700000001.000100", timestamp)
+
+	part := addCrossChannelReplyQuote(nil, otherChannelLink)
+	assert.Contains(t, part.Content.FormattedBody, otherChannelLink)
+}
+
+func TestBotIconCacheEviction(t *testing.T) {
+	mc := &MessageConverter{
+		botIconCache: make(map[string]*lru.Element),
+		botIconLRU:   lru.New(),
+	}
+	for i := 0; i < botIconCacheSize; i++ {
+		mc.cacheBotIcon(fmt.Sprintf("https://example.com/%d.png", i), id.ContentURIString(fmt.Sprintf("mxc://example.com/%d", i)))
+	}
+	// Using the oldest entry should keep it in the cache
+	_, ok := mc.getCachedBotIcon("https://example.com/0.png")
+	assert.True(t, ok)
+	mc.cacheBotIcon("https://example.com/new.png", "mxc://example.com/new")
+	_, ok = mc.getCachedBotIcon("https://example.com/0.png")
+	assert.True(t, ok)
+	_, ok = mc.getCachedBotIcon("https://example.com/1.png")
+	assert.False(t, ok)
+	assert.Len(t, mc.botIconCache, botIconCacheSize)
+}
@@ -104,7 +104,8 @@ func (n *astSlackURL) String() string {
 type astSlackSpecialMention struct {
 	astSlackTag
 
-	content string
+	content  string
+	roomPing bool
 }
 
 func (n *astSlackSpecialMention) String() string {
@@ -119,6 +120,8 @@ type Params struct {
 	ServerName     string
 	GetUserInfo    func(ctx context.Context, userID string) (mxid id.UserID, name string)
 	GetChannelInfo func(ctx context.Context, channelID string) (mxid id.RoomID, alias id.RoomAlias, name string)
+	// If set and returns true, @channel/@here/@everyone are rendered as plain text without pinging the room.
+	SuppressRoomPing func(ctx context.Context) bool
 }
 
 type slackTagParser struct {
@@ -163,12 +166,16 @@ func (s *slackTagParser) Parse(parent ast.Node, block text.Reader, pc parser.Con
 		mxid, alias, name := s.GetChannelInfo(ctx, content)
 		return &astSlackChannelMention{astSlackTag: tag, channelID: content, serverName: s.ServerName, mxid: mxid, alias: alias, name: name}
 	case "!":
+		node := &astSlackSpecialMention{astSlackTag: tag, content: content}
 		switch content {
 		case "channel", "everyone", "here":
-			pc.Get(ContextKeyMentions).(*event.Mentions).Room = true
+			if s.SuppressRoomPing == nil || !s.SuppressRoomPing(ctx) {
+				node.roomPing = true
+				pc.Get(ContextKeyMentions).(*event.Mentions).Room = true
+			}
 		default:
 		}
-		return &astSlackSpecialMention{astSlackTag: tag, content: content}
+		return node
 	case "":
 		return &astSlackURL{astSlackTag: tag, url: content}
 	default:
@@ -253,7 +260,11 @@ func (r *slackTagHTMLRenderer) renderSlackTag(w goldmarkUtil.BufWriter, source [
 			}
 			return
 		case "channel", "everyone", "here":
-			// do @room mentions?
+			if node.roomPing {
+				_, _ = w.WriteString("@room")
+			} else {
+				_, _ = fmt.Fprintf(w, "@%s", parts[0])
+			}
 			return
 		case "subteam":
 			// do subteam handling? more spaces?
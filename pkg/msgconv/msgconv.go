@@ -17,13 +17,16 @@
 package msgconv
 
 import (
+	lru "container/list"
 	"context"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/slack-go/slack"
+	"golang.org/x/sync/singleflight"
 	"maunium.net/go/mautrix/bridgev2"
 	"maunium.net/go/mautrix/bridgev2/networkid"
 	"maunium.net/go/mautrix/id"
@@ -43,6 +46,25 @@ type MessageConverter struct {
 
 	ServerName  string
 	MaxFileSize int
+
+	FormatRelayUsername func(sender *bridgev2.OrigSender) string
+	// If non-zero, @channel/@here/@everyone won't ping the Matrix room in portals with more members than this.
+	RoomMentionMemberLimit int
+	// Number of times to retry failed Matrix media uploads for live messages.
+	MediaUploadRetries int
+	// Timezone used for rendering timestamps in messages. Defaults to the local timezone if nil.
+	Timezone *time.Location
+	// Whether edits that remove all text and files should redact the message instead of editing it.
+	RedactEmptyEdits bool
+	// Whether messages posted by apps on behalf of users should be attributed to the app with a per-message profile.
+	MarkAppMessages bool
+	// Whether forwarded messages should be attributed to the author of the forwarded message.
+	QuoteAttribution bool
+
+	botIconCache     map[string]*lru.Element
+	botIconLRU       *lru.List
+	botIconCacheLock sync.Mutex
+	botIconReuploads singleflight.Group
 }
 
 type contextKey int
@@ -50,8 +72,61 @@ type contextKey int
 const (
 	contextKeyPortal contextKey = iota
 	contextKeySource
+	contextKeySuppressRoomPing
+	contextKeyBackfill
+	contextKeyEventExtras
 )
 
+// WithBackfill marks the context as belonging to a backfill, which disables things like retrying media uploads.
+func WithBackfill(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyBackfill, true)
+}
+
+func isBackfill(ctx context.Context) bool {
+	backfill, _ := ctx.Value(contextKeyBackfill).(bool)
+	return backfill
+}
+
+// HuddleRoom is the room object included in huddle messages.
+type HuddleRoom struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Participants []string `json:"participants"`
+	HasEnded     bool     `json:"has_ended"`
+}
+
+// CallInfo is the call object included in call blocks.
+type CallInfo struct {
+	ID                 string                  `json:"id"`
+	Name               string                  `json:"name"`
+	JoinURL            string                  `json:"join_url"`
+	HasEnded           bool                    `json:"has_ended"`
+	ActiveParticipants []slack.CallParticipant `json:"active_participants"`
+}
+
+// EventExtras contains the parts of message events that slackgo doesn't parse.
+type EventExtras struct {
+	HuddleRoom *HuddleRoom
+	// Call objects from call blocks, keyed by call ID
+	Calls map[string]*CallInfo
+}
+
+// WithEventExtras adds the unparsed parts of the message event to the context, so they can be used for rendering.
+func WithEventExtras(ctx context.Context, extras *EventExtras) context.Context {
+	if extras == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKeyEventExtras, extras)
+}
+
+func getEventExtras(ctx context.Context) *EventExtras {
+	extras, _ := ctx.Value(contextKeyEventExtras).(*EventExtras)
+	if extras == nil {
+		return &EventExtras{}
+	}
+	return extras
+}
+
 type SlackClientProvider interface {
 	GetClient() *slack.Client
 	GetEmoji(context.Context, string) (string, bool)
@@ -115,11 +190,15 @@ func New(br *bridgev2.Bridge, db *slackdb.SlackDB) *MessageConverter {
 		ServerName:  br.Matrix.ServerName(),
 
 		MatrixHTMLParser: matrixfmt.New2(br, db),
+		botIconCache:     make(map[string]*lru.Element),
+		botIconLRU:       lru.New(),
 	}
 	mc.SlackMrkdwnParser = mrkdwn.New(&mrkdwn.Params{
 		ServerName:     br.Matrix.ServerName(),
 		GetUserInfo:    mc.GetMentionedUserInfo,
 		GetChannelInfo: mc.GetMentionedRoomInfo,
+
+		SuppressRoomPing: roomPingSuppressed,
 	})
 	return mc
 }
@@ -18,16 +18,30 @@ package slackid
 
 import (
 	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix/bridgev2/database"
 )
 
 type PortalMetadata struct {
 	// Only present for team portals, not channels
 	TeamDomain string `json:"team_domain"`
+
+	// Per-portal override for the suppress_system_messages config option
+	SuppressSystemMessages *bool `json:"suppress_system_messages,omitempty"`
+	// The channel purpose that was last sent to the room as a state event
+	Purpose string `json:"purpose,omitempty"`
+	// The number of members in the channel as of the last resync
+	MemberCount int `json:"member_count,omitempty"`
+	// Whether the channel is the workspace's default #general channel, which can't be left
+	IsGeneral bool `json:"is_general,omitempty"`
 }
 
 type GhostMetadata struct {
 	SlackUpdatedTS int64         `json:"slack_updated_ts"`
 	LastSync       jsontime.Unix `json:"last_sync"`
+	// The presence status message that was last set based on the user's Slack status
+	StatusMessage string `json:"status_message,omitempty"`
+	// When the status message expires, zero if it doesn't
+	StatusExpiry jsontime.Unix `json:"status_expiry,omitempty"`
 }
 
 type UserLoginMetadata struct {
@@ -35,8 +49,31 @@ type UserLoginMetadata struct {
 	Token       string `json:"token"`
 	CookieToken string `json:"cookie_token,omitempty"`
 	AppToken    string `json:"app_token,omitempty"`
+
+	// Per-user display name overrides for ghosts, keyed by Slack user ID
+	NicknameOverrides map[string]string `json:"nickname_overrides,omitempty"`
+}
+
+var _ database.MetaMerger = (*UserLoginMetadata)(nil)
+
+// CopyFrom replaces the login credentials with the ones from a new login,
+// but keeps settings like nickname overrides that aren't part of the login process.
+func (ulm *UserLoginMetadata) CopyFrom(other any) {
+	otherMeta, ok := other.(*UserLoginMetadata)
+	if !ok || otherMeta == nil {
+		return
+	}
+	overrides := ulm.NicknameOverrides
+	*ulm = *otherMeta
+	if ulm.NicknameOverrides == nil {
+		ulm.NicknameOverrides = overrides
+	}
 }
 
 type MessageMetadata struct {
 	CaptionMerged bool `json:"caption_merged"`
+	// Whether a notice has already been sent about the message reaching the hot message reaction threshold
+	HotNoticeSent bool `json:"hot_notice_sent,omitempty"`
+	// The client_msg_id of the Slack message, used to detect duplicates that have a different timestamp
+	ClientMsgID string `json:"client_msg_id,omitempty"`
 }
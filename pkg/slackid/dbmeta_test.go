@@ -0,0 +1,34 @@
+// mautrix-slack - A Matrix-Slack puppeting bridge.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package slackid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserLoginMetadataCopyFromKeepsNicknames(t *testing.T) {
+	meta := &UserLoginMetadata{
+		Token:             "xoxc-old",
+		NicknameOverrides: map[string]string{"U0123": "Bob"},
+	}
+	meta.CopyFrom(&UserLoginMetadata{Token: "xoxc-new", Email: "alice@example.com"})
+	assert.Equal(t, "xoxc-new", meta.Token)
+	assert.Equal(t, "alice@example.com", meta.Email)
+	assert.Equal(t, map[string]string{"U0123": "Bob"}, meta.NicknameOverrides)
+}